@@ -7,17 +7,43 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"restapi-lesson/internal/audit"
+	auditDB "restapi-lesson/internal/audit/db"
 	"restapi-lesson/internal/buyer"
 	buyerDB "restapi-lesson/internal/buyer/db"
+	"restapi-lesson/internal/category"
+	categoryDB "restapi-lesson/internal/category/db"
 	"restapi-lesson/internal/config"
+	"restapi-lesson/internal/creditnote"
+	creditNoteDB "restapi-lesson/internal/creditnote/db"
+	"restapi-lesson/internal/invoice"
 	"restapi-lesson/internal/logging"
+	"restapi-lesson/internal/loyalty"
+	loyaltyDB "restapi-lesson/internal/loyalty/db"
 	"restapi-lesson/internal/note"
 	noteDB "restapi-lesson/internal/note/db"
+	"restapi-lesson/internal/payment"
+	paymentDB "restapi-lesson/internal/payment/db"
 	"restapi-lesson/internal/prdlist"
 	productListDB "restapi-lesson/internal/prdlist/db"
 	"restapi-lesson/internal/product"
 	productDB "restapi-lesson/internal/product/db"
+	"restapi-lesson/internal/promotion"
+	promotionDB "restapi-lesson/internal/promotion/db"
+	"restapi-lesson/internal/purchase"
+	purchaseDB "restapi-lesson/internal/purchase/db"
+	"restapi-lesson/internal/report"
+	reportDB "restapi-lesson/internal/report/db"
+	"restapi-lesson/internal/stock"
+	stockDB "restapi-lesson/internal/stock/db"
+	"restapi-lesson/internal/supplier"
+	supplierDB "restapi-lesson/internal/supplier/db"
+	"restapi-lesson/internal/tax"
+	taxDB "restapi-lesson/internal/tax/db"
+	"restapi-lesson/internal/warehouse"
+	warehouseDB "restapi-lesson/internal/warehouse/db"
 	"restapi-lesson/pkg/client/postgresql"
+	"restapi-lesson/pkg/money"
 	"time"
 
 	"github.com/julienschmidt/httprouter"
@@ -42,6 +68,29 @@ func main() {
 		errorLog.Fatalf("%v", err)
 	}
 
+	stockAlerter := stock.NewAlerter(logger, cfg.Alerts.LowStockCallback)
+
+	earnPercent, err := money.Parse(cfg.Loyalty.EarnPercent)
+	if err != nil {
+		errorLog.Fatalf("loyalty.earn_percent: %v", err)
+	}
+	loyaltyProgram := loyalty.NewProgram(earnPercent)
+
+	if cfg.Invoice.Digits < 1 {
+		errorLog.Fatalf("invoice.digits must be positive, got %d", cfg.Invoice.Digits)
+	}
+	invoiceNumbering := note.Numbering(cfg.Invoice)
+
+	taxRepository := taxDB.NewRepository(postgreSQLClient, logger)
+	logger.Info.Println("register tax handler")
+	taxHandler := tax.NewHandler(taxRepository, logger)
+	taxHandler.Register(router)
+
+	categoryRepository := categoryDB.NewRepository(postgreSQLClient, logger)
+	logger.Info.Println("register category handler")
+	categoryHandler := category.NewHandler(categoryRepository, logger)
+	categoryHandler.Register(router)
+
 	productRepository := productDB.NewRepository(postgreSQLClient, logger)
 	logger.Info.Println("register product handler")
 	productHandler := product.NewHandler(productRepository, logger)
@@ -52,16 +101,71 @@ func main() {
 	buyerHandler := buyer.NewHandler(buyerRepository, logger)
 	buyerHandler.Register(router)
 
-	noteRepository := noteDB.NewRepository(postgreSQLClient, logger)
+	noteRepository := noteDB.NewRepository(postgreSQLClient, logger, stockAlerter, loyaltyProgram, invoiceNumbering)
 	logger.Info.Println("register note handler")
 	noteHandler := note.NewHandler(noteRepository, logger)
 	noteHandler.Register(router)
 
-	productListRepository := productListDB.NewRepository(postgreSQLClient, logger)
+	invoiceRenderer := invoice.NewRenderer(invoice.Seller(cfg.Seller))
+	logger.Info.Println("register invoice handler")
+	invoiceHandler := invoice.NewHandler(noteRepository, invoiceRenderer, logger)
+	invoiceHandler.Register(router)
+
+	productListRepository := productListDB.NewRepository(postgreSQLClient, logger, stockAlerter)
 	logger.Info.Println("register productList handler")
 	productListHandler := prdlist.NewHandler(productListRepository, logger)
 	productListHandler.Register(router)
 
+	promotionRepository := promotionDB.NewRepository(postgreSQLClient, logger)
+	logger.Info.Println("register promotion handler")
+	promotionHandler := promotion.NewHandler(promotionRepository, logger)
+	promotionHandler.Register(router)
+
+	paymentRepository := paymentDB.NewRepository(postgreSQLClient, logger)
+	logger.Info.Println("register payment handler")
+	paymentHandler := payment.NewHandler(paymentRepository, logger)
+	paymentHandler.Register(router)
+
+	loyaltyRepository := loyaltyDB.NewRepository(postgreSQLClient, logger)
+	logger.Info.Println("register loyalty handler")
+	loyaltyHandler := loyalty.NewHandler(loyaltyRepository, logger)
+	loyaltyHandler.Register(router)
+
+	creditNoteRepository := creditNoteDB.NewRepository(postgreSQLClient, logger)
+	logger.Info.Println("register creditNote handler")
+	creditNoteHandler := creditnote.NewHandler(creditNoteRepository, logger)
+	creditNoteHandler.Register(router)
+
+	warehouseRepository := warehouseDB.NewRepository(postgreSQLClient, logger)
+	logger.Info.Println("register warehouse handler")
+	warehouseHandler := warehouse.NewHandler(warehouseRepository, logger)
+	warehouseHandler.Register(router)
+
+	stockRepository := stockDB.NewRepository(postgreSQLClient, logger, stockAlerter)
+	logger.Info.Println("register stock handler")
+	stockHandler := stock.NewHandler(stockRepository, logger)
+	stockHandler.Register(router)
+
+	supplierRepository := supplierDB.NewRepository(postgreSQLClient, logger)
+	logger.Info.Println("register supplier handler")
+	supplierHandler := supplier.NewHandler(supplierRepository, logger)
+	supplierHandler.Register(router)
+
+	purchaseRepository := purchaseDB.NewRepository(postgreSQLClient, logger)
+	logger.Info.Println("register purchase handler")
+	purchaseHandler := purchase.NewHandler(purchaseRepository, logger)
+	purchaseHandler.Register(router)
+
+	reportRepository := reportDB.NewRepository(postgreSQLClient, logger)
+	logger.Info.Println("register report handler")
+	reportHandler := report.NewHandler(reportRepository, logger)
+	reportHandler.Register(router)
+
+	auditRepository := auditDB.NewRepository(postgreSQLClient, logger)
+	logger.Info.Println("register audit handler")
+	auditHandler := audit.NewHandler(auditRepository, logger)
+	auditHandler.Register(router)
+
 	start(router, cfg, logger)
 }
 
@@ -80,7 +184,7 @@ func start(router *httprouter.Router, cfg *config.Config, logger *logging.Logger
 	}
 
 	server := &http.Server{
-		Handler:      router,
+		Handler:      audit.Middleware(router),
 		WriteTimeout: 15 * time.Second,
 		ReadTimeout:  15 * time.Second,
 	}
@@ -3,22 +3,84 @@
 --DROP TABLE IF EXISTS product_list CASCADE;
 --DROP TABLE IF EXISTS note CASCADE;
 
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE TABLE public.tax_category
+(
+    id   SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    rate DECIMAL(5, 2) NOT NULL,
+    inclusive BOOLEAN NOT NULL DEFAULT true,
+
+    CONSTRAINT rate_check CHECK (rate >= 0 AND rate <= 100)
+);
+
+-- category is a tree: a category with no parent_id is a top level one.
+CREATE TABLE public.category
+(
+    id   SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    parent_id INT,
+
+    CONSTRAINT parent_id_fk FOREIGN KEY (parent_id) REFERENCES public.category (id)
+);
+
+-- warehouse with the lowest id is the main warehouse.
+CREATE TABLE public.warehouse
+(
+    id   SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    address VARCHAR(255) NOT NULL DEFAULT ''
+);
+
 CREATE TABLE IF NOT EXISTS public.product
 (
     id   SERIAL PRIMARY KEY,
     name VARCHAR(100) NOT NULL,
     description VARCHAR(100) NOT NULL,
-    price DECIMAL DEFAULT 0.00,
-    amount INT,
+    price DECIMAL(12, 2) NOT NULL DEFAULT 0.00,
+    amount INT NOT NULL DEFAULT 0,
+    tax_category_id INT,
+    category_id INT,
+    reorder_threshold INT NOT NULL DEFAULT 0,
+    deleted_at TIMESTAMP,
+    search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('russian', name), 'A') || setweight(to_tsvector('russian', description), 'B')
+    ) STORED,
+
+    CONSTRAINT amount_non_negative CHECK (amount >= 0),
+    CONSTRAINT reorder_threshold_non_negative CHECK (reorder_threshold >= 0),
+    CONSTRAINT tax_category_id_fk FOREIGN KEY (tax_category_id) REFERENCES public.tax_category (id),
+    CONSTRAINT category_id_fk FOREIGN KEY (category_id) REFERENCES public.category (id),
 
     UNIQUE (name)
 );
 
+CREATE INDEX product_search_vector_idx ON public.product USING GIN (search_vector);
+
+-- product_stock holds the stock of a product per warehouse; product.amount
+-- is the total over all warehouses.
+CREATE TABLE public.product_stock
+(
+    product_id INT NOT NULL,
+    warehouse_id INT NOT NULL,
+    amount INT NOT NULL DEFAULT 0,
+
+    PRIMARY KEY (product_id, warehouse_id),
+    CONSTRAINT amount_non_negative CHECK (amount >= 0),
+    CONSTRAINT product_id_fk FOREIGN KEY (product_id) REFERENCES public.product (id),
+    CONSTRAINT warehouse_id_fk FOREIGN KEY (warehouse_id) REFERENCES public.warehouse (id)
+);
+
 CREATE TABLE public.buyer
 (
     id   SERIAL PRIMARY KEY,
     name VARCHAR(100) NOT NULL,
-    surname VARCHAR(100) NOT NULL
+    surname VARCHAR(100) NOT NULL,
+    credit_limit DECIMAL(12, 2),
+    deleted_at TIMESTAMP,
+
+    CONSTRAINT credit_limit_non_negative CHECK (credit_limit >= 0)
 );
 
 CREATE TABLE public.note
@@ -26,8 +88,25 @@ CREATE TABLE public.note
     number SERIAL PRIMARY KEY,
     date TIMESTAMP,
     buyer_id INT,
+    warehouse_id INT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'draft',
+    credit_limit_override BOOLEAN NOT NULL DEFAULT false,
+    credit_limit_override_at TIMESTAMP,
+    invoice_number VARCHAR(50) UNIQUE,
+    deleted_at TIMESTAMP,
+
+    CONSTRAINT status_check CHECK (status IN ('draft', 'confirmed', 'paid', 'cancelled')),
+    CONSTRAINT buyer_fk FOREIGN KEY (buyer_id) REFERENCES public.buyer (id),
+    CONSTRAINT warehouse_id_fk FOREIGN KEY (warehouse_id) REFERENCES public.warehouse (id)
+);
 
-    CONSTRAINT buyer_fk FOREIGN KEY (buyer_id) REFERENCES public.buyer (id)
+-- invoice_sequence counts the notes confirmed in a year. The counter row is
+-- updated by the confirming transaction, so a confirmation that rolls back
+-- leaves no gap in the invoice numbers.
+CREATE TABLE public.invoice_sequence
+(
+    year INT PRIMARY KEY,
+    last_value INT NOT NULL
 );
 
 CREATE TABLE public.product_list
@@ -36,18 +115,309 @@ CREATE TABLE public.product_list
     note_id INT,
     product_id INT,
     amount INT,
+    name VARCHAR(100) NOT NULL,
+    price DECIMAL(12, 2) NOT NULL,
+    tax_rate DECIMAL(5, 2) NOT NULL DEFAULT 0,
+    tax_inclusive BOOLEAN NOT NULL DEFAULT false,
+    deleted_at TIMESTAMP,
 
     CONSTRAINT note_id_fk FOREIGN KEY (note_id) REFERENCES public.note (number),
     CONSTRAINT product_id_fk FOREIGN KEY (product_id) REFERENCES public.product (id)
 );
 
+CREATE TABLE public.promotion
+(
+    id   SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    product_id INT,
+    percent DECIMAL(5, 2) NOT NULL DEFAULT 0,
+    amount DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    buy_quantity INT NOT NULL DEFAULT 0,
+    free_quantity INT NOT NULL DEFAULT 0,
+    starts_at TIMESTAMP,
+    ends_at TIMESTAMP,
+
+    CONSTRAINT kind_check CHECK (kind IN ('product_percent', 'note_fixed', 'buy_n_get_m')),
+    CONSTRAINT product_id_fk FOREIGN KEY (product_id) REFERENCES public.product (id)
+);
+
+CREATE TABLE public.product_list_discount
+(
+    id   SERIAL PRIMARY KEY,
+    product_list_id INT NOT NULL,
+    promotion_id INT,
+    name VARCHAR(100) NOT NULL,
+    amount DECIMAL(12, 2) NOT NULL,
+
+    CONSTRAINT product_list_id_fk FOREIGN KEY (product_list_id) REFERENCES public.product_list (id),
+    CONSTRAINT promotion_id_fk FOREIGN KEY (promotion_id) REFERENCES public.promotion (id) ON DELETE SET NULL
+);
+
+-- product_list_total breaks every line item down into subtotal, discount and
+-- the net, tax and gross parts of what is left. Tax is rounded half away from
+-- zero to kopecks, exactly like tax.Split does in Go. Deleted line items are
+-- left out.
+CREATE VIEW public.product_list_total AS
+SELECT
+    t.id, t.note_id, t.product_id, t.amount, t.tax_rate,
+    t.subtotal, t.discount,
+    CASE WHEN t.tax_inclusive THEN t.taxable - t.tax ELSE t.taxable END AS net,
+    t.tax,
+    CASE WHEN t.tax_inclusive THEN t.taxable ELSE t.taxable + t.tax END AS gross
+FROM (
+    SELECT
+        pl.id, pl.note_id, pl.product_id, pl.amount, pl.tax_rate, pl.tax_inclusive,
+        pl.price * pl.amount AS subtotal,
+        d.discount,
+        pl.price * pl.amount - d.discount AS taxable,
+        ROUND(
+            (pl.price * pl.amount - d.discount) * pl.tax_rate
+                / CASE WHEN pl.tax_inclusive THEN 100 + pl.tax_rate ELSE 100 END,
+            2
+        ) AS tax
+    FROM
+        public.product_list AS pl
+        LEFT JOIN LATERAL (
+            SELECT COALESCE(SUM(pld.amount), 0) AS discount
+            FROM public.product_list_discount AS pld
+            WHERE pld.product_list_id = pl.id
+        ) AS d ON true
+    WHERE pl.deleted_at IS NULL
+) AS t;
+
+CREATE TABLE public.credit_note
+(
+    id   SERIAL PRIMARY KEY,
+    note_id INT NOT NULL,
+    date TIMESTAMP NOT NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+
+    CONSTRAINT note_id_fk FOREIGN KEY (note_id) REFERENCES public.note (number)
+);
+
+CREATE TABLE public.credit_note_line
+(
+    id   SERIAL PRIMARY KEY,
+    credit_note_id INT NOT NULL,
+    product_list_id INT NOT NULL,
+    product_id INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    price DECIMAL(12, 2) NOT NULL,
+    amount INT NOT NULL,
+    discount DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    tax DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    gross DECIMAL(12, 2) NOT NULL,
+
+    CONSTRAINT amount_positive CHECK (amount > 0),
+    CONSTRAINT credit_note_id_fk FOREIGN KEY (credit_note_id) REFERENCES public.credit_note (id),
+    CONSTRAINT product_list_id_fk FOREIGN KEY (product_list_id) REFERENCES public.product_list (id),
+    CONSTRAINT product_id_fk FOREIGN KEY (product_id) REFERENCES public.product (id)
+);
+
+CREATE TABLE public.stock_movement
+(
+    id   SERIAL PRIMARY KEY,
+    product_id INT NOT NULL,
+    warehouse_id INT NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    quantity INT NOT NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    note_id INT,
+    product_list_id INT,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+
+    CONSTRAINT kind_check CHECK (kind IN ('receipt', 'sale', 'return', 'adjustment', 'transfer')),
+    CONSTRAINT quantity_non_zero CHECK (quantity <> 0),
+    CONSTRAINT product_id_fk FOREIGN KEY (product_id) REFERENCES public.product (id),
+    CONSTRAINT warehouse_id_fk FOREIGN KEY (warehouse_id) REFERENCES public.warehouse (id)
+);
+
+-- The stock journal is append-only: corrections are new movements.
+CREATE FUNCTION public.stock_movement_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'stock movements can not be changed or deleted';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER stock_movement_append_only
+    BEFORE UPDATE OR DELETE ON public.stock_movement
+    FOR EACH ROW EXECUTE PROCEDURE public.stock_movement_append_only();
+
+CREATE TABLE public.supplier
+(
+    id   SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    phone VARCHAR(50) NOT NULL DEFAULT '',
+    email VARCHAR(100) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE public.purchase_order
+(
+    id   SERIAL PRIMARY KEY,
+    supplier_id INT NOT NULL,
+    warehouse_id INT NOT NULL,
+    ordered_at TIMESTAMP NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'ordered',
+
+    CONSTRAINT status_check CHECK (status IN ('ordered', 'partially_received', 'received')),
+    CONSTRAINT supplier_id_fk FOREIGN KEY (supplier_id) REFERENCES public.supplier (id),
+    CONSTRAINT warehouse_id_fk FOREIGN KEY (warehouse_id) REFERENCES public.warehouse (id)
+);
+
+CREATE TABLE public.purchase_order_line
+(
+    id   SERIAL PRIMARY KEY,
+    purchase_order_id INT NOT NULL,
+    product_id INT NOT NULL,
+    quantity INT NOT NULL,
+    received INT NOT NULL DEFAULT 0,
+    cost DECIMAL(12, 2) NOT NULL DEFAULT 0,
+
+    CONSTRAINT quantity_positive CHECK (quantity > 0),
+    CONSTRAINT received_range CHECK (received >= 0 AND received <= quantity),
+    CONSTRAINT purchase_order_id_fk FOREIGN KEY (purchase_order_id) REFERENCES public.purchase_order (id),
+    CONSTRAINT product_id_fk FOREIGN KEY (product_id) REFERENCES public.product (id)
+);
+
+CREATE TABLE public.payment
+(
+    id   SERIAL PRIMARY KEY,
+    note_id INT NOT NULL,
+    amount DECIMAL(12, 2) NOT NULL,
+    method VARCHAR(20) NOT NULL,
+    paid_at TIMESTAMP NOT NULL,
+
+    CONSTRAINT amount_positive CHECK (amount > 0),
+    CONSTRAINT method_check CHECK (method IN ('cash', 'card', 'transfer', 'points')),
+    CONSTRAINT note_id_fk FOREIGN KEY (note_id) REFERENCES public.note (number)
+);
+
+-- loyalty_point is the points ledger of the buyers. Points are worth a rouble
+-- each; references to notes, credit notes and payments are kept without
+-- foreign keys, as in the stock journal.
+CREATE TABLE public.loyalty_point
+(
+    id   SERIAL PRIMARY KEY,
+    buyer_id INT NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    points DECIMAL(12, 2) NOT NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    note_id INT,
+    credit_note_id INT,
+    payment_id INT,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+
+    CONSTRAINT kind_check CHECK (kind IN ('earn', 'redeem', 'reversal')),
+    CONSTRAINT points_non_zero CHECK (points <> 0),
+    CONSTRAINT buyer_id_fk FOREIGN KEY (buyer_id) REFERENCES public.buyer (id)
+);
+
+CREATE INDEX loyalty_point_buyer_id_idx ON public.loyalty_point (buyer_id);
+CREATE INDEX loyalty_point_note_id_idx ON public.loyalty_point (note_id);
+
+CREATE FUNCTION public.loyalty_point_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'loyalty points can not be changed or deleted';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER loyalty_point_append_only
+    BEFORE UPDATE OR DELETE ON public.loyalty_point
+    FOR EACH ROW EXECUTE PROCEDURE public.loyalty_point_append_only();
+
+-- audit_log records every change made to products, buyers, notes and line
+-- items: the row as JSON before and after the change and who asked for it.
+CREATE TABLE public.audit_log
+(
+    id   SERIAL PRIMARY KEY,
+    entity VARCHAR(20) NOT NULL,
+    entity_id INT NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    before JSONB,
+    after JSONB,
+    actor VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+
+    CONSTRAINT entity_check CHECK (entity IN ('product', 'buyer', 'note', 'product_list')),
+    CONSTRAINT action_check CHECK (action IN ('create', 'update', 'delete', 'restore'))
+);
+
+CREATE INDEX audit_log_entity_idx ON public.audit_log (entity, entity_id);
+CREATE INDEX audit_log_created_at_idx ON public.audit_log (created_at);
+
+CREATE FUNCTION public.audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit log can not be changed or deleted';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON public.audit_log
+    FOR EACH ROW EXECUTE PROCEDURE public.audit_log_append_only();
+
+-- note_balance shows, for every note, its gross total, the gross total of the
+-- goods returned with credit notes, what was paid and what is still owed.
+-- Points paid on a cancelled note go back to the buyer's points, so they do
+-- not count as paid.
+CREATE VIEW public.note_balance AS
+SELECT
+    n.number, n.buyer_id, n.status,
+    COALESCE(t.gross, 0) AS gross,
+    COALESCE(c.returned, 0) AS returned,
+    COALESCE(p.paid, 0) AS paid,
+    COALESCE(t.gross, 0) - COALESCE(c.returned, 0) - COALESCE(p.paid, 0) AS outstanding
+FROM
+    public.note AS n
+    LEFT JOIN LATERAL (
+        SELECT SUM(plt.gross) AS gross
+        FROM public.product_list_total AS plt
+        WHERE plt.note_id = n.number
+    ) AS t ON true
+    LEFT JOIN LATERAL (
+        SELECT SUM(cl.gross) AS returned
+        FROM public.credit_note_line AS cl
+            INNER JOIN public.credit_note AS cn ON cn.id = cl.credit_note_id
+        WHERE cn.note_id = n.number
+    ) AS c ON true
+    LEFT JOIN LATERAL (
+        SELECT SUM(pm.amount) AS paid
+        FROM public.payment AS pm
+        WHERE pm.note_id = n.number
+            AND (pm.method <> 'points' OR n.status <> 'cancelled')
+    ) AS p ON true;
+
+-- tax_category
+INSERT INTO tax_category (name, rate, inclusive)
+VALUES ('НДС 20%', 20, true);
+INSERT INTO tax_category (name, rate, inclusive)
+VALUES ('НДС 10%', 10, true);
+
+-- warehouse
+INSERT INTO warehouse (name, address)
+VALUES ('Склад', 'ул. Промышленная, 1');
+INSERT INTO warehouse (name, address)
+VALUES ('Магазин на Ленина', 'ул. Ленина, 10');
+INSERT INTO warehouse (name, address)
+VALUES ('Магазин на Пушкина', 'ул. Пушкина, 5');
+
+-- category
+INSERT INTO category (name, parent_id)
+VALUES ('Продукты питания', NULL);
+INSERT INTO category (name, parent_id)
+VALUES ('Мясные изделия', 1);
+INSERT INTO category (name, parent_id)
+VALUES ('Молочные продукты', 1);
+INSERT INTO category (name, parent_id)
+VALUES ('Сыры', 3);
+
 -- product
-INSERT INTO product (name, description, price, amount)
-VALUES ('Колбаса', 'some description', 254.9, 50);
-INSERT INTO product (name, description, price, amount)
-VALUES ('Сыр', 'some description', 213.9, 21);
-INSERT INTO product (name, description, price, amount)
-VALUES ('Молоко', 'some description', 61.3, 30);
+INSERT INTO product (name, description, price, amount, category_id)
+VALUES ('Колбаса', 'some description', 254.9, 50, 2);
+INSERT INTO product (name, description, price, amount, category_id)
+VALUES ('Сыр', 'some description', 213.9, 21, 4);
+INSERT INTO product (name, description, price, amount, category_id)
+VALUES ('Молоко', 'some description', 61.3, 30, 3);
 
 -- buyer
 INSERT INTO buyer (name, surname)
@@ -58,20 +428,38 @@ INSERT INTO buyer (name, surname)
 VALUES ('Рон', 'Уизли');
 
 -- note
-INSERT INTO note (date, buyer_id)
-VALUES ('2022-03-25T11:11:00Z', 1);
-INSERT INTO note (date, buyer_id)
-VALUES ('2022-03-27T13:15:00Z', 2);
-INSERT INTO note (date, buyer_id)
-VALUES ('2022-03-27T16:13:00Z', 3);
+INSERT INTO note (date, buyer_id, warehouse_id, status, invoice_number)
+VALUES ('2022-03-25T11:11:00Z', 1, 1, 'confirmed', 'INV-2022-000001');
+INSERT INTO note (date, buyer_id, warehouse_id, status, invoice_number)
+VALUES ('2022-03-27T13:15:00Z', 2, 1, 'confirmed', 'INV-2022-000002');
+INSERT INTO note (date, buyer_id, warehouse_id, status, invoice_number)
+VALUES ('2022-03-27T16:13:00Z', 3, 1, 'confirmed', 'INV-2022-000003');
+
+-- invoice_sequence
+INSERT INTO invoice_sequence (year, last_value)
+VALUES (2022, 3);
 
 
 -- product_list
-INSERT INTO product_list (note_id, product_id, amount)
-VALUES (1, 2, 10);
-INSERT INTO product_list (note_id, product_id, amount)
-VALUES (1, 1, 15);
-INSERT INTO product_list (note_id, product_id, amount)
-VALUES (2, 3, 50);
-INSERT INTO product_list (note_id, product_id, amount)
-VALUES (3, 3, 150);
+INSERT INTO product_list (note_id, product_id, amount, name, price)
+VALUES (1, 2, 10, 'Сыр', 213.9);
+INSERT INTO product_list (note_id, product_id, amount, name, price)
+VALUES (1, 1, 15, 'Колбаса', 254.9);
+INSERT INTO product_list (note_id, product_id, amount, name, price)
+VALUES (2, 3, 50, 'Молоко', 61.3);
+INSERT INTO product_list (note_id, product_id, amount, name, price)
+VALUES (3, 3, 150, 'Молоко', 61.3);
+
+-- supplier
+INSERT INTO supplier (name, phone, email)
+VALUES ('Мясокомбинат', '+7 495 000-00-01', 'orders@meat.example');
+INSERT INTO supplier (name, phone, email)
+VALUES ('Молочная ферма', '+7 495 000-00-02', 'orders@milk.example');
+
+-- product_stock
+INSERT INTO product_stock (product_id, warehouse_id, amount)
+SELECT id, 1, amount FROM product;
+
+-- stock_movement
+INSERT INTO stock_movement (product_id, warehouse_id, kind, quantity, reason)
+SELECT id, 1, 'adjustment', amount, 'opening balance' FROM product WHERE amount <> 0;
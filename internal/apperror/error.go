@@ -5,6 +5,11 @@ import (
 	"fmt"
 )
 
+const (
+	conflictCode    = "NS-000004"
+	creditLimitCode = "NS-000005"
+)
+
 var (
 	ErrNotFound = NewAppError("not found", "NS-000003", "")
 )
@@ -43,6 +48,14 @@ func BadRequestError(message string) *AppError {
 	return NewAppError(message, "NS-000002", "some thing wrong with user data")
 }
 
+func ConflictError(message string) *AppError {
+	return NewAppError(message, conflictCode, "request conflicts with the current state of the data")
+}
+
+func CreditLimitError(message string) *AppError {
+	return NewAppError(message, creditLimitCode, "buyer credit limit would be exceeded, set override_credit_limit to force the operation")
+}
+
 func systemError(developerMessage string) *AppError {
 	return NewAppError("system error", "NS-000001", developerMessage)
 }
@@ -22,7 +22,7 @@ func Middleware(h appHandler) http.HandlerFunc {
 				}
 
 				err = err.(*AppError)
-				w.WriteHeader(http.StatusBadRequest)
+				w.WriteHeader(statusCode(appErr))
 				w.Write(appErr.Marshal())
 				return
 			}
@@ -32,3 +32,11 @@ func Middleware(h appHandler) http.HandlerFunc {
 		}
 	}
 }
+
+func statusCode(appErr *AppError) int {
+	switch appErr.Code {
+	case conflictCode, creditLimitCode:
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,98 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"restapi-lesson/pkg/client/postgresql"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// ActorHeader names who makes a request. The service has no users of its
+// own, so the header is trusted as it is.
+const ActorHeader = "X-Actor"
+
+// maxActorLength matches the actor column.
+const maxActorLength = 100
+
+type actorKey struct{}
+
+// Middleware puts the actor of every request into its context, where Log
+// finds it.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := strings.TrimSpace(r.Header.Get(ActorHeader))
+		if runes := []rune(actor); len(runes) > maxActorLength {
+			actor = string(runes[:maxActorLength])
+		}
+		next.ServeHTTP(w, r.WithContext(WithActor(r.Context(), actor)))
+	})
+}
+
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor is who made the request, "anonymous" when nobody said.
+func Actor(ctx context.Context) string {
+	if actor, _ := ctx.Value(actorKey{}).(string); actor != "" {
+		return actor
+	}
+	return "anonymous"
+}
+
+// tables maps an entity to its table and key column.
+var tables = map[Entity]struct{ table, key string }{
+	EntityProduct:     {"public.product", "id"},
+	EntityBuyer:       {"public.buyer", "id"},
+	EntityNote:        {"public.note", "number"},
+	EntityProductList: {"public.product_list", "id"},
+}
+
+// Snapshot reads a row of an entity as JSON, nil when there is no such row.
+// The row stays locked until the transaction ends, so that it does not change
+// between the snapshot and the change it is taken for. The search vector of
+// products is derived from the name and description and left out.
+func Snapshot(ctx context.Context, client postgresql.Client, entity Entity, id any) (json.RawMessage, error) {
+	t, ok := tables[entity]
+	if !ok {
+		return nil, fmt.Errorf("unknown audit entity %q", entity)
+	}
+	q := fmt.Sprintf(`SELECT to_jsonb(t) - 'search_vector' FROM %s AS t WHERE t.%s = $1 FOR UPDATE`, t.table, t.key)
+
+	var snapshot []byte
+	err := client.QueryRow(ctx, q, id).Scan(&snapshot)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// Log records a change of an entity made through client, which is meant to be
+// the transaction that made the change: the record is only kept if the change
+// is. before is the Snapshot taken before the change, nil for a created row;
+// the row as it is now is taken as after.
+func Log(ctx context.Context, client postgresql.Client, entity Entity, id int, action Action, before json.RawMessage) error {
+	after, err := Snapshot(ctx, client, entity, id)
+	if err != nil {
+		return err
+	}
+
+	q := `
+		INSERT INTO public.audit_log
+		    (entity, entity_id, action, before, after, actor)
+		VALUES
+		       ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err = client.Exec(ctx, q, entity, id, action, []byte(before), []byte(after), Actor(ctx))
+	return err
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"restapi-lesson/internal/audit"
+	"restapi-lesson/internal/logging"
+	"restapi-lesson/pkg/client/postgresql"
+	"strings"
+
+	"github.com/jackc/pgconn"
+)
+
+type repository struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func formatQuery(q string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(q, "\t", ""), "\n", " ")
+}
+
+// Find returns a page of the audit trail, newest first.
+func (r *repository) Find(ctx context.Context, filter audit.Filter) (audit.Trail, error) {
+	trail := audit.Trail{
+		Limit:   filter.Limit,
+		Offset:  filter.Offset,
+		Records: make([]audit.Record, 0),
+	}
+
+	where := `
+		WHERE ($1::text = '' OR entity = $1)
+		    AND ($2::int = 0 OR entity_id = $2)
+		    AND ($3::timestamp IS NULL OR created_at >= $3)
+		    AND ($4::timestamp IS NULL OR created_at < $4)
+	`
+	args := []any{string(filter.Entity), filter.EntityID, filter.Period.From, filter.Period.To}
+
+	q := `SELECT COUNT(*) FROM public.audit_log` + where
+	if err := r.client.QueryRow(ctx, q, args...).Scan(&trail.Total); err != nil {
+		return audit.Trail{}, r.wrapError(err)
+	}
+
+	q = `
+		SELECT
+		    id, entity, entity_id, action, before, after, actor, created_at
+		FROM
+		    public.audit_log
+	` + where + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT $5 OFFSET $6
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return audit.Trail{}, r.wrapError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rec audit.Record
+		var before, after []byte
+
+		err = rows.Scan(&rec.ID, &rec.Entity, &rec.EntityID, &rec.Action, &before, &after, &rec.Actor, &rec.CreatedAt)
+		if err != nil {
+			return audit.Trail{}, err
+		}
+		rec.Before, rec.After = before, after
+
+		trail.Records = append(trail.Records, rec)
+	}
+
+	return trail, rows.Err()
+}
+
+func (r *repository) wrapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+	return err
+}
+
+func NewRepository(client postgresql.Client, logger *logging.Logger) audit.Repository {
+	return &repository{
+		client: client,
+		logger: logger,
+	}
+}
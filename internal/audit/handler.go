@@ -0,0 +1,104 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/handlers"
+	"restapi-lesson/internal/logging"
+	"restapi-lesson/pkg/daterange"
+	"strconv"
+)
+
+const (
+	auditURL = "/audit"
+)
+
+type handler struct {
+	logger     *logging.Logger
+	repository Repository
+}
+
+func NewHandler(repository Repository, logger *logging.Logger) handlers.Handler {
+	return &handler{
+		repository: repository,
+		logger:     logger,
+	}
+}
+
+func (h *handler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, auditURL, apperror.Middleware(h.GetAudit))
+}
+
+// GetAudit answers e.g. /audit?entity=product&id=2&from=2026-01-01&to=2026-01-31
+// with the changes made to product 2 in January.
+func (h *handler) GetAudit(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("GET AUDIT")
+	w.Header().Set("Content-Type", "application/json")
+
+	filter, err := filterFromQuery(r)
+	if err != nil {
+		return err
+	}
+
+	trail, err := h.repository.Find(r.Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	trailBytes, err := json.Marshal(trail)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(trailBytes)
+
+	return nil
+}
+
+// filterFromQuery reads the entity, id, from, to, limit and offset query
+// parameters.
+func filterFromQuery(r *http.Request) (Filter, error) {
+	query := r.URL.Query()
+	filter := Filter{Limit: defaultLimit}
+
+	if entity := Entity(query.Get("entity")); entity != "" {
+		if !entity.Valid() {
+			return Filter{}, apperror.BadRequestError(fmt.Sprintf("unknown entity %q", entity))
+		}
+		filter.Entity = entity
+	}
+
+	var err error
+	if id := query.Get("id"); id != "" {
+		if filter.Entity == "" {
+			return Filter{}, apperror.BadRequestError("id needs an entity")
+		}
+		filter.EntityID, err = strconv.Atoi(id)
+		if err != nil || filter.EntityID < 1 {
+			return Filter{}, apperror.BadRequestError("id must be a positive integer")
+		}
+	}
+
+	if filter.Period, err = daterange.FromQuery(query); err != nil {
+		return Filter{}, apperror.BadRequestError(err.Error())
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit < 1 || filter.Limit > maxLimit {
+			return Filter{}, apperror.BadRequestError(fmt.Sprintf("limit must be an integer from 1 to %d", maxLimit))
+		}
+	}
+	if offset := query.Get("offset"); offset != "" {
+		filter.Offset, err = strconv.Atoi(offset)
+		if err != nil || filter.Offset < 0 {
+			return Filter{}, apperror.BadRequestError("offset must be a non-negative integer")
+		}
+	}
+
+	return filter, nil
+}
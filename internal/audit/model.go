@@ -0,0 +1,67 @@
+package audit
+
+import (
+	"encoding/json"
+	"restapi-lesson/pkg/daterange"
+	"time"
+)
+
+type Entity string
+
+const (
+	EntityProduct     Entity = "product"
+	EntityBuyer       Entity = "buyer"
+	EntityNote        Entity = "note"
+	EntityProductList Entity = "product_list"
+)
+
+func (e Entity) Valid() bool {
+	_, ok := tables[e]
+	return ok
+}
+
+type Action string
+
+const (
+	ActionCreate  Action = "create"
+	ActionUpdate  Action = "update"
+	ActionDelete  Action = "delete"
+	ActionRestore Action = "restore"
+)
+
+// Record is a change of an entity. Before and After are the row as JSON
+// before and after the change; Before is null for a created row. Actor is who
+// made the request that changed it.
+type Record struct {
+	ID        int             `json:"id"`
+	Entity    Entity          `json:"entity"`
+	EntityID  int             `json:"entity_id"`
+	Action    Action          `json:"action"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	Actor     string          `json:"actor"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+// Filter selects a page of the records made within Period. An empty Entity
+// matches every entity and a zero EntityID every row of it.
+type Filter struct {
+	Entity   Entity
+	EntityID int
+	Period   daterange.Range
+	Limit    int
+	Offset   int
+}
+
+// Trail is a page of records, newest first.
+type Trail struct {
+	Total   int      `json:"total"`
+	Limit   int      `json:"limit"`
+	Offset  int      `json:"offset"`
+	Records []Record `json:"records"`
+}
@@ -0,0 +1,9 @@
+package audit
+
+import (
+	"context"
+)
+
+type Repository interface {
+	Find(ctx context.Context, filter Filter) (Trail, error)
+}
@@ -4,12 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/audit"
 	"restapi-lesson/internal/buyer"
 	"restapi-lesson/internal/logging"
 	"restapi-lesson/pkg/client/postgresql"
 	"strings"
 
 	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
 )
 
 type repository struct {
@@ -22,37 +25,43 @@ func formatQuery(q string) string {
 }
 
 func (r *repository) Create(ctx context.Context, buyer *buyer.Buyer) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
 	q := `
 		INSERT INTO public.buyer 
-		    (name, surname) 
+		    (name, surname, credit_limit) 
 		VALUES 
-		       ($1, $2) 
+		       ($1, $2, $3) 
 		RETURNING id
 	`
 	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
-	if err := r.client.QueryRow(ctx, q, buyer.Name, buyer.Surname).Scan(&buyer.ID); err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			pgErr = err.(*pgconn.PgError)
-			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
-			r.logger.Err.Println(newErr)
-			return newErr
-		}
-		return err
+	if err = tx.QueryRow(ctx, q, buyer.Name, buyer.Surname, buyer.CreditLimit).Scan(&buyer.ID); err != nil {
+		return r.wrapError(err)
 	}
 
-	return nil
+	if err = audit.Log(ctx, tx, audit.EntityBuyer, buyer.ID, audit.ActionCreate, nil); err != nil {
+		return r.wrapError(err)
+	}
+
+	return tx.Commit(ctx)
 }
 
-func (r *repository) FindAll(ctx context.Context) (u []buyer.Buyer, err error) {
+// FindAll lists the buyers, leaving out deleted ones unless includeDeleted is
+// set.
+func (r *repository) FindAll(ctx context.Context, includeDeleted bool) (u []buyer.Buyer, err error) {
 	q := `
 		SELECT
-		    id, name, surname
+		    id, name, surname, credit_limit, deleted_at
 		FROM
 		    public.buyer
+		WHERE $1 OR deleted_at IS NULL
 	`
 
-	rows, err := r.client.Query(ctx, q)
+	rows, err := r.client.Query(ctx, q, includeDeleted)
 	if err != nil {
 		return nil, err
 	}
@@ -62,7 +71,7 @@ func (r *repository) FindAll(ctx context.Context) (u []buyer.Buyer, err error) {
 	for rows.Next() {
 		var buyer buyer.Buyer
 
-		err = rows.Scan(&buyer.ID, &buyer.Name, &buyer.Surname)
+		err = rows.Scan(&buyer.ID, &buyer.Name, &buyer.Surname, &buyer.CreditLimit, &buyer.DeletedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -80,7 +89,7 @@ func (r *repository) FindAll(ctx context.Context) (u []buyer.Buyer, err error) {
 func (r *repository) FindOne(ctx context.Context, id string) (buyer.Buyer, error) {
 	q := `
 		SELECT
-		    id, name, surname
+		    id, name, surname, credit_limit, deleted_at
 		FROM
 		    public.buyer
 		WHERE id = $1
@@ -88,7 +97,7 @@ func (r *repository) FindOne(ctx context.Context, id string) (buyer.Buyer, error
 	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
 
 	var br buyer.Buyer
-	err := r.client.QueryRow(ctx, q, id).Scan(&br.ID, &br.Name, &br.Surname)
+	err := r.client.QueryRow(ctx, q, id).Scan(&br.ID, &br.Name, &br.Surname, &br.CreditLimit, &br.DeletedAt)
 	if err != nil {
 		return buyer.Buyer{}, err
 	}
@@ -97,25 +106,29 @@ func (r *repository) FindOne(ctx context.Context, id string) (buyer.Buyer, error
 }
 
 func (r *repository) Update(ctx context.Context, buyer buyer.Buyer) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := audit.Snapshot(ctx, tx, audit.EntityBuyer, buyer.ID)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
 	q := `
 		UPDATE 
     		public.buyer
 		SET
-			name = $1, surname = $2
+			name = $1, surname = $2, credit_limit = $3
 		WHERE
-		    id = $3
+		    id = $4 AND deleted_at IS NULL
 	`
 
-	commandTag, err := r.client.Exec(ctx, q, buyer.Name, buyer.Surname, buyer.ID)
+	commandTag, err := tx.Exec(ctx, q, buyer.Name, buyer.Surname, buyer.CreditLimit, buyer.ID)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			pgErr = err.(*pgconn.PgError)
-			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
-			r.logger.Err.Println(newErr)
-			return newErr
-		}
-		return err
+		return r.wrapError(err)
 	}
 	if commandTag.RowsAffected() != 1 {
 		newErr := errors.New("no row found to update")
@@ -123,30 +136,83 @@ func (r *repository) Update(ctx context.Context, buyer buyer.Buyer) error {
 		return newErr
 	}
 
-	return nil
+	if err = audit.Log(ctx, tx, audit.EntityBuyer, buyer.ID, audit.ActionUpdate, before); err != nil {
+		return r.wrapError(err)
+	}
+
+	return tx.Commit(ctx)
 }
 
+// Delete marks the buyer as deleted. Their notes are kept and the buyer can be
+// restored.
 func (r *repository) Delete(ctx context.Context, id string) error {
-	q := `DELETE FROM public.buyer WHERE id = $1`
-	commandTag, err := r.client.Exec(ctx, q, id)
+	tx, err := r.client.Begin(ctx)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			pgErr = err.(*pgconn.PgError)
-			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
-			r.logger.Err.Println(newErr)
-			return newErr
-		}
 		return err
 	}
+	defer tx.Rollback(ctx)
 
-	if commandTag.RowsAffected() != 1 {
+	before, err := audit.Snapshot(ctx, tx, audit.EntityBuyer, id)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	q := `UPDATE public.buyer SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL RETURNING id`
+	var buyerID int
+	err = tx.QueryRow(ctx, q, id).Scan(&buyerID)
+	if errors.Is(err, pgx.ErrNoRows) {
 		newErr := errors.New("no row found to delete")
 		r.logger.Err.Println(newErr)
 		return newErr
 	}
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	if err = audit.Log(ctx, tx, audit.EntityBuyer, buyerID, audit.ActionDelete, before); err != nil {
+		return r.wrapError(err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *repository) Restore(ctx context.Context, id string) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := audit.Snapshot(ctx, tx, audit.EntityBuyer, id)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	q := `UPDATE public.buyer SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL RETURNING id`
+	var buyerID int
+	err = tx.QueryRow(ctx, q, id).Scan(&buyerID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return apperror.ErrNotFound
+	}
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	if err = audit.Log(ctx, tx, audit.EntityBuyer, buyerID, audit.ActionRestore, before); err != nil {
+		return r.wrapError(err)
+	}
+
+	return tx.Commit(ctx)
+}
 
-	return nil
+func (r *repository) wrapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+	return err
 }
 
 func NewRepository(client postgresql.Client, logger *logging.Logger) buyer.Repository {
@@ -12,8 +12,9 @@ import (
 )
 
 const (
-	buyersURL = "/buyers"
-	buyerURL  = "/buyers/:uuid"
+	buyersURL       = "/buyers"
+	buyerURL        = "/buyers/:uuid"
+	buyerRestoreURL = "/buyers/:uuid/restore"
 )
 
 type handler struct {
@@ -34,6 +35,7 @@ func (h *handler) Register(router *httprouter.Router) {
 	router.HandlerFunc(http.MethodPost, buyersURL, apperror.Middleware(h.CreateBuyer))
 	router.HandlerFunc(http.MethodPatch, buyerURL, apperror.Middleware(h.UpdateBuyer))
 	router.HandlerFunc(http.MethodDelete, buyerURL, apperror.Middleware(h.DeleteBuyer))
+	router.HandlerFunc(http.MethodPost, buyerRestoreURL, apperror.Middleware(h.RestoreBuyer))
 }
 
 func (h *handler) GetBuyer(w http.ResponseWriter, r *http.Request) error {
@@ -68,7 +70,8 @@ func (h *handler) GetAllBuyers(w http.ResponseWriter, r *http.Request) error {
 
 	h.logger.Info.Println("get category_uuid from URL")
 
-	buyers, err := h.repository.FindAll(r.Context())
+	includeDeleted := r.URL.Query().Get("include_deleted") == "true"
+	buyers, err := h.repository.FindAll(r.Context(), includeDeleted)
 	if err != nil {
 		return err
 	}
@@ -159,3 +162,33 @@ func (h *handler) DeleteBuyer(w http.ResponseWriter, r *http.Request) error {
 
 	return nil
 }
+
+func (h *handler) RestoreBuyer(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("RESTORE BUYER")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	buyerUUID := params.ByName("uuid")
+	if buyerUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+
+	if err := h.repository.Restore(r.Context(), buyerUUID); err != nil {
+		return err
+	}
+
+	buyer, err := h.repository.FindOne(r.Context(), buyerUUID)
+	if err != nil {
+		return err
+	}
+	buyerBytes, err := json.Marshal(buyer)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(buyerBytes)
+
+	return nil
+}
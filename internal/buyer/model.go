@@ -1,7 +1,17 @@
 package buyer
 
+import (
+	"restapi-lesson/pkg/money"
+	"time"
+)
+
+// Buyer may have a credit limit: the most they are allowed to owe across
+// unpaid notes. No limit is enforced when it is not set. A deleted buyer has
+// DeletedAt set and gets no new notes until they are restored.
 type Buyer struct {
-	ID      int    `json:"id"`
-	Name    string `json:"name"`
-	Surname string `json:"surname"`
+	ID          int          `json:"id"`
+	Name        string       `json:"name"`
+	Surname     string       `json:"surname"`
+	CreditLimit *money.Money `json:"credit_limit,omitempty"`
+	DeletedAt   *time.Time   `json:"deleted_at,omitempty"`
 }
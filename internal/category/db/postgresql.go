@@ -0,0 +1,296 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/category"
+	"restapi-lesson/internal/logging"
+	"restapi-lesson/internal/product"
+	"restapi-lesson/pkg/client/postgresql"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+type repository struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func formatQuery(q string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(q, "\t", ""), "\n", " ")
+}
+
+// lockTree keeps concurrent changes of parents from building a cycle between
+// them; the mode conflicts with itself but not with reads.
+func lockTree(ctx context.Context, client postgresql.Client) error {
+	_, err := client.Exec(ctx, `LOCK TABLE public.category IN SHARE ROW EXCLUSIVE MODE`)
+	return err
+}
+
+// checkParent makes sure the parent exists and that category id is neither
+// the parent itself nor one of its ancestors, which would turn the tree into a
+// cycle. id is 0 for a new category.
+func (r *repository) checkParent(ctx context.Context, client postgresql.Client, id int, parentID *int) error {
+	if parentID == nil {
+		return nil
+	}
+
+	q := `
+		WITH RECURSIVE ancestors AS (
+		    SELECT id, parent_id FROM public.category WHERE id = $1
+		    UNION
+		    SELECT c.id, c.parent_id
+		    FROM
+		        public.category AS c
+		        INNER JOIN ancestors AS a ON c.id = a.parent_id
+		)
+		SELECT
+		    COUNT(*) > 0, COALESCE(BOOL_OR(id = $2), false)
+		FROM
+		    ancestors
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+
+	var exists, cycle bool
+	if err := client.QueryRow(ctx, q, *parentID, id).Scan(&exists, &cycle); err != nil {
+		return err
+	}
+	if !exists {
+		return apperror.BadRequestError(fmt.Sprintf("parent category %d does not exist", *parentID))
+	}
+	if cycle {
+		return apperror.BadRequestError(fmt.Sprintf("category %d can not be moved under itself or its descendant %d", id, *parentID))
+	}
+
+	return nil
+}
+
+func (r *repository) Create(ctx context.Context, category *category.Category) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err = lockTree(ctx, tx); err != nil {
+		return r.wrapError(err)
+	}
+	if err = r.checkParent(ctx, tx, 0, category.ParentID); err != nil {
+		return r.wrapError(err)
+	}
+
+	q := `
+		INSERT INTO public.category
+		    (name, parent_id)
+		VALUES
+		       ($1, $2)
+		RETURNING id
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+	if err = tx.QueryRow(ctx, q, category.Name, category.ParentID).Scan(&category.ID); err != nil {
+		return r.wrapError(err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// FindAll lists the categories by name; category.Tree nests them.
+func (r *repository) FindAll(ctx context.Context) ([]category.Category, error) {
+	q := `
+		SELECT
+		    id, name, parent_id
+		FROM
+		    public.category
+		ORDER BY name, id
+	`
+
+	rows, err := r.client.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := make([]category.Category, 0)
+
+	for rows.Next() {
+		var c category.Category
+
+		err = rows.Scan(&c.ID, &c.Name, &c.ParentID)
+		if err != nil {
+			return nil, err
+		}
+
+		categories = append(categories, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return categories, nil
+}
+
+func (r *repository) FindOne(ctx context.Context, id string) (category.Category, error) {
+	q := `
+		SELECT
+		    id, name, parent_id
+		FROM
+		    public.category
+		WHERE id = $1
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+
+	var c category.Category
+	err := r.client.QueryRow(ctx, q, id).Scan(&c.ID, &c.Name, &c.ParentID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return category.Category{}, apperror.ErrNotFound
+	}
+	if err != nil {
+		return category.Category{}, err
+	}
+
+	return c, nil
+}
+
+func (r *repository) Update(ctx context.Context, category category.Category) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err = lockTree(ctx, tx); err != nil {
+		return r.wrapError(err)
+	}
+	if err = r.checkParent(ctx, tx, category.ID, category.ParentID); err != nil {
+		return r.wrapError(err)
+	}
+
+	q := `
+		UPDATE
+    		public.category
+		SET
+			name = $1, parent_id = $2
+		WHERE
+		    id = $3
+	`
+
+	commandTag, err := tx.Exec(ctx, q, category.Name, category.ParentID, category.ID)
+	if err != nil {
+		return r.wrapError(err)
+	}
+	if commandTag.RowsAffected() != 1 {
+		newErr := errors.New("no row found to update")
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Delete removes a category that has neither subcategories nor products.
+func (r *repository) Delete(ctx context.Context, id string) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err = lockTree(ctx, tx); err != nil {
+		return r.wrapError(err)
+	}
+
+	q := `
+		SELECT
+		    (SELECT COUNT(*) FROM public.category WHERE parent_id = $1),
+		    (SELECT COUNT(*) FROM public.product WHERE category_id = $1)
+	`
+
+	var children, products int
+	if err = tx.QueryRow(ctx, q, id).Scan(&children, &products); err != nil {
+		return r.wrapError(err)
+	}
+	if children > 0 || products > 0 {
+		return apperror.ConflictError(fmt.Sprintf("category %s still has %d subcategories and %d products", id, children, products))
+	}
+
+	commandTag, err := tx.Exec(ctx, `DELETE FROM public.category WHERE id = $1`, id)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	if commandTag.RowsAffected() != 1 {
+		newErr := errors.New("no row found to delete")
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+
+	return tx.Commit(ctx)
+}
+
+// FindProducts lists the products of a category and of all its descendants.
+func (r *repository) FindProducts(ctx context.Context, id string) ([]product.Product, error) {
+	if _, err := r.FindOne(ctx, id); err != nil {
+		return nil, err
+	}
+
+	q := `
+		WITH RECURSIVE subtree AS (
+		    SELECT id FROM public.category WHERE id = $1
+		    UNION
+		    SELECT c.id
+		    FROM
+		        public.category AS c
+		        INNER JOIN subtree AS s ON c.parent_id = s.id
+		)
+		SELECT
+		    p.id, p.name, p.description, p.price, p.amount, p.tax_category_id, p.category_id, p.reorder_threshold
+		FROM
+		    public.product AS p
+		WHERE p.category_id IN (SELECT id FROM subtree) AND p.deleted_at IS NULL
+		ORDER BY p.name, p.id
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q, id)
+	if err != nil {
+		return nil, r.wrapError(err)
+	}
+	defer rows.Close()
+
+	products := make([]product.Product, 0)
+
+	for rows.Next() {
+		var prd product.Product
+
+		err = rows.Scan(&prd.ID, &prd.Name, &prd.Description, &prd.Price, &prd.Amount, &prd.TaxCategoryID, &prd.CategoryID, &prd.ReorderThreshold)
+		if err != nil {
+			return nil, err
+		}
+
+		products = append(products, prd)
+	}
+
+	return products, rows.Err()
+}
+
+func (r *repository) wrapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+	return err
+}
+
+func NewRepository(client postgresql.Client, logger *logging.Logger) category.Repository {
+	return &repository{
+		client: client,
+		logger: logger,
+	}
+}
@@ -0,0 +1,198 @@
+package category
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/handlers"
+	"restapi-lesson/internal/logging"
+	"strconv"
+)
+
+const (
+	categoriesURL       = "/categories"
+	categoryURL         = "/categories/:uuid"
+	categoryProductsURL = "/categories/:uuid/products"
+)
+
+type handler struct {
+	logger     *logging.Logger
+	repository Repository
+}
+
+func NewHandler(repository Repository, logger *logging.Logger) handlers.Handler {
+	return &handler{
+		repository: repository,
+		logger:     logger,
+	}
+}
+
+func (h *handler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, categoryURL, apperror.Middleware(h.GetCategory))
+	router.HandlerFunc(http.MethodGet, categoriesURL, apperror.Middleware(h.GetAllCategories))
+	router.HandlerFunc(http.MethodPost, categoriesURL, apperror.Middleware(h.CreateCategory))
+	router.HandlerFunc(http.MethodPatch, categoryURL, apperror.Middleware(h.UpdateCategory))
+	router.HandlerFunc(http.MethodDelete, categoryURL, apperror.Middleware(h.DeleteCategory))
+	router.HandlerFunc(http.MethodGet, categoryProductsURL, apperror.Middleware(h.GetCategoryProducts))
+}
+
+func (h *handler) GetCategory(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("GET CATEGORY")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	categoryUUID := params.ByName("uuid")
+	if categoryUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+	h.logger.Info.Printf("get param: %v", categoryUUID)
+
+	category, err := h.repository.FindOne(r.Context(), categoryUUID)
+	if err != nil {
+		return err
+	}
+	categoryBytes, err := json.Marshal(category)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(categoryBytes)
+
+	return nil
+}
+
+func (h *handler) GetAllCategories(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("GET ALL CATEGORIES")
+	w.Header().Set("Content-Type", "application/json")
+
+	categories, err := h.repository.FindAll(r.Context())
+	if err != nil {
+		return err
+	}
+
+	categoriesBytes, err := json.Marshal(Tree(categories))
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(categoriesBytes)
+
+	return nil
+}
+
+func (h *handler) CreateCategory(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("CREATE CATEGORY")
+	w.Header().Set("Content-Type", "application/json")
+
+	var ctg Category
+
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&ctg); err != nil {
+		return apperror.BadRequestError("invalid data")
+	}
+	if err := ctg.Validate(); err != nil {
+		return err
+	}
+
+	err := h.repository.Create(r.Context(), &ctg)
+	if err != nil {
+		return err
+	}
+
+	categoryUUID := ctg.ID
+	w.Header().Set("Location", fmt.Sprintf("%s/%v", categoriesURL, categoryUUID))
+	w.WriteHeader(http.StatusCreated)
+
+	return nil
+}
+
+func (h *handler) UpdateCategory(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("UPDATE CATEGORY")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	categoryUUID := params.ByName("uuid")
+	if categoryUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+
+	id, err := strconv.Atoi(categoryUUID)
+	if err != nil {
+		return err
+	}
+
+	var ctg Category
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&ctg); err != nil {
+		return apperror.BadRequestError("invalid data")
+	}
+	ctg.ID = id
+	if err = ctg.Validate(); err != nil {
+		return err
+	}
+
+	err = h.repository.Update(r.Context(), ctg)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *handler) DeleteCategory(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("DELETE CATEGORY")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	categoryUUID := params.ByName("uuid")
+	if categoryUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+
+	err := h.repository.Delete(r.Context(), categoryUUID)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// GetCategoryProducts lists the products of the category together with those
+// of its subcategories.
+func (h *handler) GetCategoryProducts(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("GET CATEGORY PRODUCTS")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	categoryUUID := params.ByName("uuid")
+	if categoryUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+	h.logger.Info.Printf("get param: %v", categoryUUID)
+
+	products, err := h.repository.FindProducts(r.Context(), categoryUUID)
+	if err != nil {
+		return err
+	}
+	productsBytes, err := json.Marshal(products)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(productsBytes)
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package category
+
+import "restapi-lesson/internal/apperror"
+
+// Category is a node of the category tree; a category without ParentID is a
+// top level one.
+type Category struct {
+	ID       int        `json:"id"`
+	Name     string     `json:"name"`
+	ParentID *int       `json:"parent_id"`
+	Children []Category `json:"children,omitempty"`
+}
+
+func (c Category) Validate() error {
+	if c.Name == "" {
+		return apperror.BadRequestError("name is required")
+	}
+	return nil
+}
+
+// Tree nests a flat list of categories under their parents and returns the
+// top level ones. The order of siblings follows the order of the list.
+func Tree(categories []Category) []Category {
+	children := make(map[int][]Category)
+	for _, c := range categories {
+		if c.ParentID != nil {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+		}
+	}
+
+	var attach func(c Category) Category
+	attach = func(c Category) Category {
+		for _, child := range children[c.ID] {
+			c.Children = append(c.Children, attach(child))
+		}
+		return c
+	}
+
+	roots := make([]Category, 0)
+	for _, c := range categories {
+		if c.ParentID == nil {
+			roots = append(roots, attach(c))
+		}
+	}
+	return roots
+}
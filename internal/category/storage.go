@@ -0,0 +1,15 @@
+package category
+
+import (
+	"context"
+	"restapi-lesson/internal/product"
+)
+
+type Repository interface {
+	Create(ctx context.Context, category *Category) error
+	FindAll(ctx context.Context) ([]Category, error)
+	FindOne(ctx context.Context, id string) (Category, error)
+	Update(ctx context.Context, category Category) error
+	Delete(ctx context.Context, id string) error
+	FindProducts(ctx context.Context, id string) ([]product.Product, error)
+}
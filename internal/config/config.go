@@ -14,6 +14,38 @@ type Config struct {
 		Port   string `yaml:"port" env-default:"8080"`
 	} `yaml:"listen"`
 	Storage StorageConfig `yaml:"storage"`
+	Alerts  AlertsConfig  `yaml:"alerts"`
+	Loyalty LoyaltyConfig `yaml:"loyalty"`
+	Seller  SellerConfig  `yaml:"seller"`
+	Invoice InvoiceConfig `yaml:"invoice"`
+}
+
+// AlertsConfig holds where alerts are sent besides the log. An empty
+// LowStockCallback turns the low stock callback off.
+type AlertsConfig struct {
+	LowStockCallback string `yaml:"low_stock_callback" env:"LOW_STOCK_CALLBACK"`
+}
+
+// LoyaltyConfig holds the share of a confirmed note's gross total, in
+// percent with up to two decimal places, that the buyer earns as points.
+// "0" turns earning off.
+type LoyaltyConfig struct {
+	EarnPercent string `yaml:"earn_percent" env:"LOYALTY_EARN_PERCENT" env-default:"0"`
+}
+
+// SellerConfig is the company printed at the top of invoices.
+type SellerConfig struct {
+	Name    string `yaml:"name" env:"SELLER_NAME"`
+	Address string `yaml:"address" env:"SELLER_ADDRESS"`
+	TaxID   string `yaml:"tax_id" env:"SELLER_TAX_ID"`
+	Phone   string `yaml:"phone" env:"SELLER_PHONE"`
+}
+
+// InvoiceConfig is the look of the numbers given to notes on confirmation,
+// e.g. INV-2026-000123 for prefix INV and six digits.
+type InvoiceConfig struct {
+	Prefix string `yaml:"prefix" env:"INVOICE_PREFIX" env-default:"INV"`
+	Digits int    `yaml:"digits" env:"INVOICE_DIGITS" env-default:"6"`
 }
 
 type StorageConfig struct {
@@ -0,0 +1,328 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/creditnote"
+	"restapi-lesson/internal/logging"
+	"restapi-lesson/internal/loyalty"
+	"restapi-lesson/internal/note"
+	"restapi-lesson/internal/stock"
+	"restapi-lesson/internal/tax"
+	"restapi-lesson/pkg/client/postgresql"
+	"restapi-lesson/pkg/money"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+type repository struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func formatQuery(q string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(q, "\t", ""), "\n", " ")
+}
+
+func (r *repository) Create(ctx context.Context, creditNote *creditnote.CreditNote) error {
+	if creditNote.Date.IsZero() {
+		creditNote.Date = time.Now()
+	}
+
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Holding the note keeps it from being cancelled while goods are returned,
+	// which would put the same units back into stock and take back the same
+	// loyalty points twice.
+	q := `
+		SELECT
+		    status, warehouse_id
+		FROM
+		    public.note
+		WHERE number = $1
+		FOR SHARE
+	`
+
+	var status note.Status
+	var warehouseID int
+	err = tx.QueryRow(ctx, q, creditNote.NoteID).Scan(&status, &warehouseID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return apperror.BadRequestError(fmt.Sprintf("note %d does not exist", creditNote.NoteID))
+	}
+	if err != nil {
+		return r.wrapError(err)
+	}
+	if status != note.StatusConfirmed && status != note.StatusPaid {
+		return apperror.ConflictError(fmt.Sprintf("note %d is %s, only confirmed or paid notes can be returned", creditNote.NoteID, status))
+	}
+
+	q = `
+		INSERT INTO public.credit_note 
+		    (note_id, date, reason) 
+		VALUES 
+		       ($1, $2, $3) 
+		RETURNING id
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+	if err = tx.QueryRow(ctx, q, creditNote.NoteID, creditNote.Date, creditNote.Reason).Scan(&creditNote.ID); err != nil {
+		return r.wrapError(err)
+	}
+
+	var returned money.Money
+	returning := make(map[int]int)
+	for i := range creditNote.Lines {
+		line := &creditNote.Lines[i]
+		if line.Amount <= 0 {
+			return apperror.BadRequestError("returned amount must be a positive integer")
+		}
+
+		if err = r.lockSold(ctx, tx, creditNote.NoteID, line); err != nil {
+			return r.wrapError(err)
+		}
+
+		returning[line.ProductListID] += line.Amount
+		returned = returned.Sub(line.TotalCount)
+		if err = r.checkReturnable(ctx, tx, line.ProductListID, returning[line.ProductListID]); err != nil {
+			return r.wrapError(err)
+		}
+	}
+
+	productIDs := make([]int, 0, len(creditNote.Lines))
+	for _, line := range creditNote.Lines {
+		productIDs = append(productIDs, line.ProductID)
+	}
+	if err = stock.Lock(ctx, tx, productIDs...); err != nil {
+		return r.wrapError(err)
+	}
+
+	q = `
+		INSERT INTO public.credit_note_line 
+		    (credit_note_id, product_list_id, product_id, name, price, amount, discount, tax, gross) 
+		VALUES 
+		       ($1, $2, $3, $4, $5, $6, $7, $8, $9) 
+		RETURNING id
+	`
+	for i := range creditNote.Lines {
+		line := &creditNote.Lines[i]
+
+		ref := stock.Ref{
+			Kind:          stock.KindReturn,
+			Reason:        fmt.Sprintf("credit note %d", creditNote.ID),
+			WarehouseID:   warehouseID,
+			NoteID:        creditNote.NoteID,
+			ProductListID: line.ProductListID,
+		}
+		if err = stock.Release(ctx, tx, line.ProductID, line.Amount, ref); err != nil {
+			return r.wrapError(err)
+		}
+
+		err = tx.QueryRow(ctx, q, creditNote.ID, line.ProductListID, line.ProductID, line.Name, line.Price, line.Amount, line.Discount, line.Tax, line.TotalCount.Neg()).Scan(&line.ID)
+		if err != nil {
+			return r.wrapError(err)
+		}
+	}
+
+	if err = loyalty.ReverseReturn(ctx, tx, creditNote.NoteID, creditNote.ID, returned); err != nil {
+		return r.wrapError(err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// lockSold locks the product list row a line refers to and fills the line
+// with what was sold, including its share of the discount given on the row
+// and the tax on what is given back. The lock serializes concurrent returns
+// of the same row.
+func (r *repository) lockSold(ctx context.Context, client postgresql.Client, noteID int, line *creditnote.Line) error {
+	q := `
+		SELECT
+		    pl.product_id, pl.name, pl.price, pl.amount, pl.tax_rate, pl.tax_inclusive,
+		    COALESCE((SELECT SUM(pld.amount) FROM public.product_list_discount AS pld WHERE pld.product_list_id = pl.id), 0)
+		FROM
+		    public.product_list AS pl
+		WHERE pl.id = $1 AND pl.note_id = $2 AND pl.deleted_at IS NULL
+		FOR UPDATE
+	`
+
+	var sold int
+	var taxRate, discount money.Money
+	var taxInclusive bool
+	err := client.QueryRow(ctx, q, line.ProductListID, noteID).
+		Scan(&line.ProductID, &line.Name, &line.Price, &sold, &taxRate, &taxInclusive, &discount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return apperror.BadRequestError(fmt.Sprintf("product list %d does not belong to note %d", line.ProductListID, noteID))
+	}
+	if err != nil {
+		return err
+	}
+
+	line.Discount = discount.MulDiv(int64(line.Amount), int64(sold))
+
+	var gross money.Money
+	_, line.Tax, gross = tax.Split(line.Price.Mul(line.Amount).Sub(line.Discount), taxRate, taxInclusive)
+	line.TotalCount = gross.Neg()
+
+	return nil
+}
+
+// checkReturnable makes sure that, together with earlier returns, no more
+// units come back than were sold on the product list row.
+func (r *repository) checkReturnable(ctx context.Context, client postgresql.Client, productListID, amount int) error {
+	q := `
+		SELECT
+		    pl.amount,
+		    COALESCE((SELECT SUM(cl.amount) FROM public.credit_note_line AS cl WHERE cl.product_list_id = pl.id), 0)
+		FROM
+		    public.product_list AS pl
+		WHERE pl.id = $1
+	`
+
+	var sold, returned int
+	if err := client.QueryRow(ctx, q, productListID).Scan(&sold, &returned); err != nil {
+		return err
+	}
+
+	if returned+amount > sold {
+		return apperror.ConflictError(fmt.Sprintf("product list %d: %d sold, %d already returned, can not return %d more", productListID, sold, returned, amount))
+	}
+
+	return nil
+}
+
+const creditNoteQuery = `
+		SELECT
+		    cn.id, cn.note_id, cn.date, cn.reason,
+		    -COALESCE((SELECT SUM(cl.gross) FROM public.credit_note_line AS cl WHERE cl.credit_note_id = cn.id), 0)
+		FROM
+		    public.credit_note AS cn
+	`
+
+func (r *repository) attachLines(ctx context.Context, creditNotes []creditnote.CreditNote) error {
+	if len(creditNotes) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*creditnote.CreditNote, len(creditNotes))
+	ids := make([]int, 0, len(creditNotes))
+	for i := range creditNotes {
+		byID[creditNotes[i].ID] = &creditNotes[i]
+		ids = append(ids, creditNotes[i].ID)
+	}
+
+	q := `
+		SELECT
+		    credit_note_id, id, product_list_id, product_id, name, price, amount, discount, tax, -gross
+		FROM
+		    public.credit_note_line
+		WHERE credit_note_id = ANY($1)
+		ORDER BY credit_note_id, id
+	`
+
+	rows, err := r.client.Query(ctx, q, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var creditNoteID int
+		var line creditnote.Line
+
+		err = rows.Scan(&creditNoteID, &line.ID, &line.ProductListID, &line.ProductID, &line.Name, &line.Price, &line.Amount, &line.Discount, &line.Tax, &line.TotalCount)
+		if err != nil {
+			return err
+		}
+
+		cn := byID[creditNoteID]
+		cn.Lines = append(cn.Lines, line)
+	}
+
+	return rows.Err()
+}
+
+func (r *repository) FindAll(ctx context.Context) ([]creditnote.CreditNote, error) {
+	q := creditNoteQuery + `
+		ORDER BY cn.id
+	`
+
+	rows, err := r.client.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	creditNotes := make([]creditnote.CreditNote, 0)
+
+	for rows.Next() {
+		var cn creditnote.CreditNote
+
+		err = rows.Scan(&cn.ID, &cn.NoteID, &cn.Date, &cn.Reason, &cn.Total)
+		if err != nil {
+			return nil, err
+		}
+
+		cn.Lines = make([]creditnote.Line, 0)
+		creditNotes = append(creditNotes, cn)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err = r.attachLines(ctx, creditNotes); err != nil {
+		return nil, err
+	}
+
+	return creditNotes, nil
+}
+
+func (r *repository) FindOne(ctx context.Context, id string) (creditnote.CreditNote, error) {
+	q := creditNoteQuery + `
+		WHERE cn.id = $1
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+
+	var cn creditnote.CreditNote
+	err := r.client.QueryRow(ctx, q, id).Scan(&cn.ID, &cn.NoteID, &cn.Date, &cn.Reason, &cn.Total)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return creditnote.CreditNote{}, apperror.ErrNotFound
+	}
+	if err != nil {
+		return creditnote.CreditNote{}, err
+	}
+	cn.Lines = make([]creditnote.Line, 0)
+
+	creditNotes := []creditnote.CreditNote{cn}
+	if err = r.attachLines(ctx, creditNotes); err != nil {
+		return creditnote.CreditNote{}, err
+	}
+
+	return creditNotes[0], nil
+}
+
+func (r *repository) wrapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+	return err
+}
+
+func NewRepository(client postgresql.Client, logger *logging.Logger) creditnote.Repository {
+	return &repository{
+		client: client,
+		logger: logger,
+	}
+}
@@ -0,0 +1,118 @@
+package creditnote
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/handlers"
+	"restapi-lesson/internal/logging"
+	"strconv"
+)
+
+const (
+	creditNotesURL = "/creditnotes"
+	creditNoteURL  = "/creditnotes/:uuid"
+)
+
+type handler struct {
+	logger     *logging.Logger
+	repository Repository
+}
+
+func NewHandler(repository Repository, logger *logging.Logger) handlers.Handler {
+	return &handler{
+		repository: repository,
+		logger:     logger,
+	}
+}
+
+func (h *handler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, creditNoteURL, apperror.Middleware(h.GetCreditNote))
+	router.HandlerFunc(http.MethodGet, creditNotesURL, apperror.Middleware(h.GetAllCreditNotes))
+	router.HandlerFunc(http.MethodPost, creditNotesURL, apperror.Middleware(h.CreateCreditNote))
+}
+
+func (h *handler) GetCreditNote(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("GET CREDIT NOTE")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	creditNoteUUID := params.ByName("uuid")
+	if creditNoteUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+
+	creditNote, err := h.repository.FindOne(r.Context(), creditNoteUUID)
+	if err != nil {
+		return err
+	}
+
+	creditNoteBytes, err := json.Marshal(creditNote)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(creditNoteBytes)
+
+	return nil
+}
+
+func (h *handler) GetAllCreditNotes(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("GET ALL CREDIT NOTES")
+	w.Header().Set("Content-Type", "application/json")
+
+	creditNotes, err := h.repository.FindAll(r.Context())
+	if err != nil {
+		return err
+	}
+
+	creditNotesBytes, err := json.Marshal(creditNotes)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(creditNotesBytes)
+
+	return nil
+}
+
+func (h *handler) CreateCreditNote(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("CREATE CREDIT NOTE")
+	w.Header().Set("Content-Type", "application/json")
+
+	var cn CreditNote
+
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&cn); err != nil {
+		return apperror.BadRequestError("invalid data")
+	}
+	if len(cn.Lines) == 0 {
+		return apperror.BadRequestError("credit note must have at least one line")
+	}
+
+	err := h.repository.Create(r.Context(), &cn)
+	if err != nil {
+		return err
+	}
+
+	created, err := h.repository.FindOne(r.Context(), strconv.Itoa(cn.ID))
+	if err != nil {
+		return err
+	}
+
+	creditNoteBytes, err := json.Marshal(created)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/%v", creditNotesURL, cn.ID))
+	w.WriteHeader(http.StatusCreated)
+	w.Write(creditNoteBytes)
+
+	return nil
+}
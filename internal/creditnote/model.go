@@ -0,0 +1,29 @@
+package creditnote
+
+import (
+	"restapi-lesson/pkg/money"
+	"time"
+)
+
+// CreditNote records goods a buyer returned from an earlier note. Its totals
+// are negative, so they can be added to invoice totals as they are.
+type CreditNote struct {
+	ID     int         `json:"id"`
+	NoteID int         `json:"note_id"`
+	Date   time.Time   `json:"date"`
+	Reason string      `json:"reason"`
+	Lines  []Line      `json:"lines"`
+	Total  money.Money `json:"total"`
+}
+
+type Line struct {
+	ID            int         `json:"id"`
+	ProductListID int         `json:"product_list_id"`
+	ProductID     int         `json:"product_id"`
+	Name          string      `json:"name"`
+	Price         money.Money `json:"price"`
+	Amount        int         `json:"amount"`
+	Discount      money.Money `json:"discount"`
+	Tax           money.Money `json:"tax"`
+	TotalCount    money.Money `json:"total_count"`
+}
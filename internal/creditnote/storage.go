@@ -0,0 +1,11 @@
+package creditnote
+
+import (
+	"context"
+)
+
+type Repository interface {
+	Create(ctx context.Context, creditNote *CreditNote) error
+	FindAll(ctx context.Context) ([]CreditNote, error)
+	FindOne(ctx context.Context, id string) (CreditNote, error)
+}
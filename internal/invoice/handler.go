@@ -0,0 +1,92 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/handlers"
+	"restapi-lesson/internal/logging"
+	"restapi-lesson/internal/note"
+)
+
+const (
+	invoicePDFURL  = "/notes/:uuid/invoice.pdf"
+	invoiceHTMLURL = "/notes/:uuid/invoice.html"
+)
+
+type handler struct {
+	logger     *logging.Logger
+	repository note.Repository
+	renderer   *Renderer
+}
+
+func NewHandler(repository note.Repository, renderer *Renderer, logger *logging.Logger) handlers.Handler {
+	return &handler{
+		repository: repository,
+		renderer:   renderer,
+		logger:     logger,
+	}
+}
+
+func (h *handler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, invoicePDFURL, apperror.Middleware(h.GetInvoicePDF))
+	router.HandlerFunc(http.MethodGet, invoiceHTMLURL, apperror.Middleware(h.GetInvoiceHTML))
+}
+
+// GetInvoicePDF renders into a buffer first, so that a rendering error still
+// gets the usual JSON error response.
+func (h *handler) GetInvoicePDF(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("GET INVOICE PDF")
+
+	nt, err := h.findNote(r)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err = h.renderer.PDF(&buf, nt); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="invoice-%d.pdf"`, nt.Number))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+
+	return nil
+}
+
+func (h *handler) GetInvoiceHTML(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("GET INVOICE HTML")
+
+	nt, err := h.findNote(r)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err = h.renderer.HTML(&buf, nt); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+
+	return nil
+}
+
+// findNote loads the note of the request together with its buyer.
+func (h *handler) findNote(r *http.Request) (note.NoteWithPrdList, error) {
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	noteUUID := params.ByName("uuid")
+	if noteUUID == "" {
+		return note.NoteWithPrdList{}, apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+	h.logger.Info.Printf("get param: %v", noteUUID)
+
+	return h.repository.FindOne(r.Context(), noteUUID, note.Options{WithBuyer: true})
+}
@@ -0,0 +1,18 @@
+package invoice
+
+import (
+	"embed"
+	"html/template"
+	"io"
+	"restapi-lesson/internal/note"
+)
+
+//go:embed templates/invoice.html
+var templates embed.FS
+
+var htmlTemplate = template.Must(template.ParseFS(templates, "templates/invoice.html"))
+
+// HTML writes the note as an HTML page ready to be printed from a browser.
+func (r *Renderer) HTML(w io.Writer, nt note.NoteWithPrdList) error {
+	return htmlTemplate.Execute(w, newDocument(r.seller, nt))
+}
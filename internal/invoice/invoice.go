@@ -0,0 +1,159 @@
+package invoice
+
+import (
+	"fmt"
+	"restapi-lesson/internal/note"
+	"restapi-lesson/pkg/money"
+	"strings"
+)
+
+// Seller is the company printed at the top of every invoice.
+type Seller struct {
+	Name    string
+	Address string
+	TaxID   string
+	Phone   string
+}
+
+// Renderer turns notes into printable invoices, as HTML or PDF.
+type Renderer struct {
+	seller Seller
+}
+
+func NewRenderer(seller Seller) *Renderer {
+	return &Renderer{seller: seller}
+}
+
+// document is a note laid out for printing: every amount is already
+// formatted, so the HTML and PDF renderers only place the text.
+type document struct {
+	Seller  Seller
+	Title   string
+	Status  string
+	Buyer   string
+	Lines   []line
+	Taxes   []total
+	Totals  []total
+	Columns []string
+}
+
+type line struct {
+	No       int
+	Name     string
+	Amount   string
+	Price    string
+	Discount string
+	TaxRate  string
+	Tax      string
+	Gross    string
+}
+
+type total struct {
+	Label string
+	Value string
+	Bold  bool
+}
+
+var statusLabels = map[note.Status]string{
+	note.StatusDraft:     "Черновик",
+	note.StatusCancelled: "Отменена",
+}
+
+var columns = []string{"№", "Товар", "Кол-во", "Цена", "Скидка", "НДС, %", "НДС", "Сумма"}
+
+func newDocument(seller Seller, nt note.NoteWithPrdList) document {
+	doc := document{
+		Seller:  seller,
+		Title:   fmt.Sprintf("Накладная № %s от %s", invoiceNumber(nt), nt.Date.Format("02.01.2006")),
+		Status:  statusLabels[nt.Status],
+		Columns: columns,
+	}
+
+	if nt.Buyer != nil {
+		doc.Buyer = strings.TrimSpace(nt.Buyer.Name + " " + nt.Buyer.Surname)
+	}
+
+	for i, pl := range nt.PrdLists {
+		doc.Lines = append(doc.Lines, line{
+			No:       i + 1,
+			Name:     pl.Name,
+			Amount:   fmt.Sprint(pl.Amount),
+			Price:    formatMoney(pl.Price),
+			Discount: formatMoney(pl.Discount),
+			TaxRate:  formatRate(pl.TaxRate),
+			Tax:      formatMoney(pl.Tax),
+			Gross:    formatMoney(pl.Gross),
+		})
+	}
+
+	for _, group := range nt.Taxes {
+		doc.Taxes = append(doc.Taxes, total{
+			Label: fmt.Sprintf("в т. ч. НДС %s%%", formatRate(group.Rate)),
+			Value: formatMoney(group.Tax),
+		})
+	}
+
+	// A draft is printed as quoted, with the discounts it would be confirmed
+	// with.
+	s := nt.Summary
+	if q := nt.Quote; q != nil {
+		s.Discount, s.Net, s.Tax, s.GrandTotal, s.Outstanding = q.Discount, q.Net, q.Tax, q.GrandTotal, q.Outstanding
+	}
+	doc.Totals = append(doc.Totals, total{Label: "Сумма", Value: formatMoney(s.Subtotal)})
+	if !s.Discount.IsZero() {
+		doc.Totals = append(doc.Totals, total{Label: "Скидка", Value: formatMoney(s.Discount)})
+	}
+	doc.Totals = append(doc.Totals,
+		total{Label: "Без НДС", Value: formatMoney(s.Net)},
+		total{Label: "НДС", Value: formatMoney(s.Tax)},
+		total{Label: "Итого", Value: formatMoney(s.GrandTotal), Bold: true},
+	)
+	if !s.Returned.IsZero() {
+		doc.Totals = append(doc.Totals, total{Label: "Возвращено", Value: formatMoney(s.Returned)})
+	}
+	if !s.Paid.IsZero() {
+		doc.Totals = append(doc.Totals, total{Label: "Оплачено", Value: formatMoney(s.Paid)})
+	}
+	doc.Totals = append(doc.Totals, total{Label: "К оплате", Value: formatMoney(s.Outstanding), Bold: true})
+
+	return doc
+}
+
+// invoiceNumber is the invoice number of the note. A note never confirmed has
+// none and is printed with its internal number.
+func invoiceNumber(nt note.NoteWithPrdList) string {
+	if nt.InvoiceNumber != nil {
+		return *nt.InvoiceNumber
+	}
+	return fmt.Sprint(nt.Number)
+}
+
+// formatMoney writes an amount the Russian way: 1 234,50.
+func formatMoney(m money.Money) string {
+	s := m.String()
+
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, fraction, _ := strings.Cut(s, ".")
+
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteByte(' ')
+		}
+		grouped.WriteRune(digit)
+	}
+
+	return sign + grouped.String() + "," + fraction
+}
+
+// formatRate drops the zero fraction of a tax rate: 20, but 12,5.
+func formatRate(rate money.Money) string {
+	s := formatMoney(rate)
+	if strings.HasSuffix(s, ",00") {
+		return strings.TrimSuffix(s, ",00")
+	}
+	return strings.TrimSuffix(s, "0")
+}
@@ -0,0 +1,179 @@
+package invoice
+
+import (
+	"fmt"
+	"io"
+	"restapi-lesson/internal/note"
+	"restapi-lesson/pkg/pdf"
+
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// Page layout in points.
+const (
+	margin     = 40.0
+	rowHeight  = 14.0
+	bodySize   = 9.0
+	footerSize = 8.0
+)
+
+// column is a table column: text starts at x, or ends at x when the column
+// is right aligned.
+type column struct {
+	x     float64
+	right bool
+}
+
+var tableColumns = []column{
+	{x: margin},
+	{x: margin + 22},
+	{x: 290, right: true},
+	{x: 355, right: true},
+	{x: 410, right: true},
+	{x: 450, right: true},
+	{x: 500, right: true},
+	{x: pdf.A4Width - margin, right: true},
+}
+
+// nameWidth is the room for a product name, leaving space for the quantity.
+const nameWidth = 250 - (margin + 22)
+
+// pdfLayout draws a document page by page, from the top of the page down.
+type pdfLayout struct {
+	doc           *pdf.Document
+	page          *pdf.Page
+	regular, bold *pdf.Font
+	y             float64
+}
+
+// PDF writes the note as an A4 PDF document. The Go fonts are embedded, so the
+// invoice prints the same everywhere.
+func (r *Renderer) PDF(w io.Writer, nt note.NoteWithPrdList) error {
+	d := newDocument(r.seller, nt)
+
+	l := &pdfLayout{doc: pdf.NewDocument(pdf.A4Width, pdf.A4Height)}
+	var err error
+	if l.regular, err = l.doc.AddFont(goregular.TTF); err != nil {
+		return err
+	}
+	if l.bold, err = l.doc.AddFont(gobold.TTF); err != nil {
+		return err
+	}
+	l.newPage()
+
+	l.text(l.bold, 13, margin, d.Seller.Name)
+	for _, s := range []string{d.Seller.Address, prefixed("ИНН ", d.Seller.TaxID), prefixed("Тел. ", d.Seller.Phone)} {
+		if s != "" {
+			l.text(l.regular, bodySize, margin, s)
+		}
+	}
+	l.y -= 4
+	l.rule(0.8)
+	l.y -= 10
+
+	l.text(l.bold, 15, margin, d.Title)
+	if d.Status != "" {
+		l.text(l.bold, 11, margin, d.Status)
+	}
+	if d.Buyer != "" {
+		l.y -= 4
+		l.text(l.regular, 10, margin, "Покупатель: "+d.Buyer)
+	}
+	l.y -= 10
+
+	l.tableHeader(d.Columns)
+	for _, ln := range d.Lines {
+		if l.y < margin+rowHeight {
+			l.newPage()
+			l.tableHeader(d.Columns)
+		}
+		cells := []string{fmt.Sprint(ln.No), l.fit(ln.Name, nameWidth), ln.Amount, ln.Price, ln.Discount, ln.TaxRate, ln.Tax, ln.Gross}
+		l.row(l.regular, cells)
+	}
+	l.y += rowHeight - 4
+	l.rule(0.5)
+	l.y -= 14
+
+	totals := append(append([]total{}, d.Totals...), d.Taxes...)
+	if l.y < margin+rowHeight*float64(len(totals)) {
+		l.newPage()
+	}
+	labelX := tableColumns[5].x
+	for _, t := range totals {
+		f := l.regular
+		if t.Bold {
+			f = l.bold
+		}
+		l.page.Text(f, 10, labelX-f.Width(t.Label, 10), l.y, t.Label)
+		l.page.Text(f, 10, pdf.A4Width-margin-f.Width(t.Value, 10), l.y, t.Value)
+		l.y -= rowHeight
+	}
+
+	l.footer(d.Title)
+
+	_, err = l.doc.WriteTo(w)
+	return err
+}
+
+func prefixed(prefix, s string) string {
+	if s == "" {
+		return ""
+	}
+	return prefix + s
+}
+
+func (l *pdfLayout) newPage() {
+	l.page = l.doc.AddPage()
+	l.y = pdf.A4Height - margin - 13
+}
+
+// text writes a line of text and moves down to the next line.
+func (l *pdfLayout) text(f *pdf.Font, size, x float64, s string) {
+	l.page.Text(f, size, x, l.y, s)
+	l.y -= size * 1.4
+}
+
+func (l *pdfLayout) rule(width float64) {
+	l.page.Line(margin, l.y, pdf.A4Width-margin, l.y, width)
+}
+
+func (l *pdfLayout) tableHeader(titles []string) {
+	l.row(l.bold, titles)
+	l.y += rowHeight - 4
+	l.rule(0.5)
+	l.y -= rowHeight - 4
+}
+
+func (l *pdfLayout) row(f *pdf.Font, cells []string) {
+	for i, cell := range cells {
+		c := tableColumns[i]
+		x := c.x
+		if c.right {
+			x -= f.Width(cell, bodySize)
+		}
+		l.page.Text(f, bodySize, x, l.y, cell)
+	}
+	l.y -= rowHeight
+}
+
+// fit cuts s short with an ellipsis so that it is no wider than width.
+func (l *pdfLayout) fit(s string, width float64) string {
+	if l.regular.Width(s, bodySize) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && l.regular.Width(string(runes)+"…", bodySize) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
+
+// footer numbers the pages once all of them are laid out.
+func (l *pdfLayout) footer(title string) {
+	count := l.doc.PageCount()
+	for i := 0; i < count; i++ {
+		s := fmt.Sprintf("%s — страница %d из %d", title, i+1, count)
+		l.doc.Page(i).Text(l.regular, footerSize, pdf.A4Width-margin-l.regular.Width(s, footerSize), margin/2, s)
+	}
+}
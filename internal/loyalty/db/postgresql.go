@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/logging"
+	"restapi-lesson/internal/loyalty"
+	"restapi-lesson/pkg/client/postgresql"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+type repository struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func formatQuery(q string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(q, "\t", ""), "\n", " ")
+}
+
+func (r *repository) FindByBuyer(ctx context.Context, buyerID string) (loyalty.Ledger, error) {
+	q := `
+		SELECT
+		    b.id, COALESCE((SELECT SUM(lp.points) FROM public.loyalty_point AS lp WHERE lp.buyer_id = b.id), 0)
+		FROM
+		    public.buyer AS b
+		WHERE b.id = $1
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+
+	var ledger loyalty.Ledger
+	err := r.client.QueryRow(ctx, q, buyerID).Scan(&ledger.BuyerID, &ledger.Balance)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return loyalty.Ledger{}, apperror.ErrNotFound
+	}
+	if err != nil {
+		return loyalty.Ledger{}, r.wrapError(err)
+	}
+
+	q = `
+		SELECT
+		    id, buyer_id, kind, points, reason, note_id, credit_note_id, payment_id, created_at
+		FROM
+		    public.loyalty_point
+		WHERE buyer_id = $1
+		ORDER BY created_at DESC, id DESC
+	`
+
+	rows, err := r.client.Query(ctx, q, buyerID)
+	if err != nil {
+		return loyalty.Ledger{}, r.wrapError(err)
+	}
+	defer rows.Close()
+
+	ledger.Entries = make([]loyalty.Entry, 0)
+
+	for rows.Next() {
+		var e loyalty.Entry
+
+		err = rows.Scan(&e.ID, &e.BuyerID, &e.Kind, &e.Points, &e.Reason, &e.NoteID, &e.CreditNoteID, &e.PaymentID, &e.CreatedAt)
+		if err != nil {
+			return loyalty.Ledger{}, err
+		}
+
+		ledger.Entries = append(ledger.Entries, e)
+	}
+
+	return ledger, rows.Err()
+}
+
+func (r *repository) wrapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+	return err
+}
+
+func NewRepository(client postgresql.Client, logger *logging.Logger) loyalty.Repository {
+	return &repository{
+		client: client,
+		logger: logger,
+	}
+}
@@ -0,0 +1,57 @@
+package loyalty
+
+import (
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/handlers"
+	"restapi-lesson/internal/logging"
+)
+
+const (
+	buyerPointsURL = "/buyers/:uuid/points"
+)
+
+type handler struct {
+	logger     *logging.Logger
+	repository Repository
+}
+
+func NewHandler(repository Repository, logger *logging.Logger) handlers.Handler {
+	return &handler{
+		repository: repository,
+		logger:     logger,
+	}
+}
+
+func (h *handler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, buyerPointsURL, apperror.Middleware(h.GetBuyerPoints))
+}
+
+func (h *handler) GetBuyerPoints(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("GET BUYER POINTS")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	buyerUUID := params.ByName("uuid")
+	if buyerUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+
+	ledger, err := h.repository.FindByBuyer(r.Context(), buyerUUID)
+	if err != nil {
+		return err
+	}
+
+	ledgerBytes, err := json.Marshal(ledger)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(ledgerBytes)
+
+	return nil
+}
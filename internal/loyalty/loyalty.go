@@ -0,0 +1,156 @@
+package loyalty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/pkg/client/postgresql"
+	"restapi-lesson/pkg/money"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Program is the loyalty programme: buyers earn EarnPercent of the gross total
+// of every note when it is confirmed. A nil Program or a zero rate earns
+// nothing, while points already earned can still be redeemed.
+type Program struct {
+	EarnPercent money.Money
+}
+
+func NewProgram(earnPercent money.Money) *Program {
+	return &Program{EarnPercent: earnPercent}
+}
+
+// Earn credits the buyer with points for a note confirmed at the given gross
+// total.
+func (p *Program) Earn(ctx context.Context, client postgresql.Client, buyerID, noteID int, gross money.Money) error {
+	if p == nil {
+		return nil
+	}
+
+	points := gross.Percent(p.EarnPercent)
+	if points.Cmp(money.Money{}) <= 0 {
+		return nil
+	}
+
+	return record(ctx, client, Entry{
+		BuyerID: buyerID,
+		Kind:    KindEarn,
+		Points:  points,
+		Reason:  fmt.Sprintf("note %d confirmed", noteID),
+		NoteID:  &noteID,
+	})
+}
+
+// Redeem debits points the buyer pays with. The buyer row is locked, so
+// concurrent payments can not spend the same points twice.
+func Redeem(ctx context.Context, client postgresql.Client, buyerID, noteID, paymentID int, points money.Money) error {
+	q := `
+		SELECT
+		    b.id, COALESCE((SELECT SUM(lp.points) FROM public.loyalty_point AS lp WHERE lp.buyer_id = b.id), 0)
+		FROM
+		    public.buyer AS b
+		WHERE b.id = $1
+		FOR UPDATE OF b
+	`
+
+	var id int
+	var balance money.Money
+	err := client.QueryRow(ctx, q, buyerID).Scan(&id, &balance)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return apperror.BadRequestError(fmt.Sprintf("buyer %d does not exist", buyerID))
+	}
+	if err != nil {
+		return err
+	}
+	if balance.Cmp(points) < 0 {
+		return apperror.ConflictError(fmt.Sprintf("buyer %d has %s points, can not redeem %s", buyerID, balance, points))
+	}
+
+	return record(ctx, client, Entry{
+		BuyerID:   buyerID,
+		Kind:      KindRedeem,
+		Points:    points.Neg(),
+		Reason:    fmt.Sprintf("payment on note %d", noteID),
+		NoteID:    &noteID,
+		PaymentID: &paymentID,
+	})
+}
+
+// ReverseNote undoes everything a cancelled note did to the points balance:
+// points earned on it, less those already taken back for returns, are taken
+// back and points paid with on it are given back.
+func ReverseNote(ctx context.Context, client postgresql.Client, noteID int) error {
+	q := `
+		INSERT INTO public.loyalty_point
+		    (buyer_id, kind, points, reason, note_id)
+		SELECT
+		    buyer_id, $2, -SUM(points), $3, $1
+		FROM
+		    public.loyalty_point
+		WHERE note_id = $1
+		GROUP BY buyer_id
+		HAVING SUM(points) <> 0
+	`
+
+	_, err := client.Exec(ctx, q, noteID, KindReversal, fmt.Sprintf("note %d cancelled", noteID))
+	return err
+}
+
+// ReverseReturn takes back the points earned on goods returned with a credit
+// note, in proportion to the returned share of the note's gross total. Never
+// more is taken back than was earned on the note.
+func ReverseReturn(ctx context.Context, client postgresql.Client, noteID, creditNoteID int, returned money.Money) error {
+	q := `
+		SELECT
+		    lp.buyer_id,
+		    COALESCE(SUM(lp.points) FILTER (WHERE lp.kind = 'earn'), 0),
+		    -COALESCE(SUM(lp.points) FILTER (WHERE lp.kind = 'reversal' AND lp.credit_note_id IS NOT NULL), 0),
+		    nb.gross
+		FROM
+		    public.loyalty_point AS lp
+		    INNER JOIN public.note_balance AS nb ON nb.number = lp.note_id
+		WHERE lp.note_id = $1
+		GROUP BY lp.buyer_id, nb.gross
+	`
+
+	var buyerID int
+	var earned, reversed, gross money.Money
+	err := client.QueryRow(ctx, q, noteID).Scan(&buyerID, &earned, &reversed, &gross)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if gross.Cmp(money.Money{}) <= 0 {
+		return nil
+	}
+
+	points := money.Min(earned.MulDiv(returned.Cents(), gross.Cents()), earned.Sub(reversed))
+	if points.Cmp(money.Money{}) <= 0 {
+		return nil
+	}
+
+	return record(ctx, client, Entry{
+		BuyerID:      buyerID,
+		Kind:         KindReversal,
+		Points:       points.Neg(),
+		Reason:       fmt.Sprintf("credit note %d", creditNoteID),
+		NoteID:       &noteID,
+		CreditNoteID: &creditNoteID,
+	})
+}
+
+func record(ctx context.Context, client postgresql.Client, e Entry) error {
+	q := `
+		INSERT INTO public.loyalty_point
+		    (buyer_id, kind, points, reason, note_id, credit_note_id, payment_id)
+		VALUES
+		       ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := client.Exec(ctx, q, e.BuyerID, e.Kind, e.Points, e.Reason, e.NoteID, e.CreditNoteID, e.PaymentID)
+	return err
+}
@@ -0,0 +1,41 @@
+package loyalty
+
+import (
+	"restapi-lesson/pkg/money"
+	"time"
+)
+
+// Kind says why the points balance of a buyer changed.
+type Kind string
+
+const (
+	// KindEarn credits points for a confirmed note.
+	KindEarn Kind = "earn"
+	// KindRedeem debits points spent on a payment.
+	KindRedeem Kind = "redeem"
+	// KindReversal undoes points of a cancelled note or of returned goods.
+	KindReversal Kind = "reversal"
+)
+
+// Entry is a line of the points ledger. Points are positive when credited
+// and negative when debited; a point is worth one rouble when redeemed.
+type Entry struct {
+	ID           int         `json:"id"`
+	BuyerID      int         `json:"buyer_id"`
+	Kind         Kind        `json:"kind"`
+	Points       money.Money `json:"points"`
+	Reason       string      `json:"reason"`
+	NoteID       *int        `json:"note_id,omitempty"`
+	CreditNoteID *int        `json:"credit_note_id,omitempty"`
+	PaymentID    *int        `json:"payment_id,omitempty"`
+	CreatedAt    time.Time   `json:"created_at"`
+}
+
+// Ledger is the points history of a buyer, latest first. Balance is the sum
+// of all entries; it can drop below zero when points already spent are taken
+// back for returned goods.
+type Ledger struct {
+	BuyerID int         `json:"buyer_id"`
+	Balance money.Money `json:"balance"`
+	Entries []Entry     `json:"entries"`
+}
@@ -0,0 +1,9 @@
+package loyalty
+
+import (
+	"context"
+)
+
+type Repository interface {
+	FindByBuyer(ctx context.Context, buyerID string) (Ledger, error)
+}
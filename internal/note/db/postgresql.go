@@ -4,166 +4,675 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/audit"
+	"restapi-lesson/internal/buyer"
 	"restapi-lesson/internal/logging"
+	"restapi-lesson/internal/loyalty"
 	"restapi-lesson/internal/note"
+	"restapi-lesson/internal/prdlist"
+	productListDB "restapi-lesson/internal/prdlist/db"
+	"restapi-lesson/internal/promotion"
+	promotionDB "restapi-lesson/internal/promotion/db"
+	"restapi-lesson/internal/stock"
+	"restapi-lesson/internal/tax"
 	"restapi-lesson/pkg/client/postgresql"
+	"restapi-lesson/pkg/money"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
 )
 
 type repository struct {
-	client postgresql.Client
-	logger *logging.Logger
+	client    postgresql.Client
+	logger    *logging.Logger
+	alerter   *stock.Alerter
+	loyalty   *loyalty.Program
+	numbering note.Numbering
 }
 
 func formatQuery(q string) string {
 	return strings.ReplaceAll(strings.ReplaceAll(q, "\t", ""), "\n", " ")
 }
 
-func (r *repository) Create(ctx context.Context, note *note.Note) error {
+func (r *repository) Create(ctx context.Context, nt *note.Note) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if nt.WarehouseID, err = stock.Warehouse(ctx, tx, nt.WarehouseID); err != nil {
+		return err
+	}
+
 	q := `
 		INSERT INTO public.note 
-		    (date, buyer_id) 
+		    (date, buyer_id, warehouse_id) 
 		VALUES 
-		       ($1, $2) 
+		       ($1, $2, $3) 
 		RETURNING number
 	`
 	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
-	if err := r.client.QueryRow(ctx, q, note.Date, note.BuyerID).Scan(&note.Number); err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			pgErr = err.(*pgconn.PgError)
-			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
-			r.logger.Err.Println(newErr)
-			return newErr
+	if err = tx.QueryRow(ctx, q, nt.Date, nt.BuyerID, nt.WarehouseID).Scan(&nt.Number); err != nil {
+		return r.wrapError(err)
+	}
+
+	productIDs := make([]int, 0, len(nt.Items))
+	for _, item := range nt.Items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	if err = stock.Lock(ctx, tx, productIDs...); err != nil {
+		return r.wrapError(err)
+	}
+
+	var alerts []stock.LowStock
+	for _, item := range nt.Items {
+		pl := prdlist.ProductList{
+			NoteID:    nt.Number,
+			ProductID: item.ProductID,
+			Amount:    item.Amount,
 		}
+		low, err := productListDB.Insert(ctx, tx, &pl)
+		if err != nil {
+			return r.wrapError(err)
+		}
+		if low != nil {
+			alerts = append(alerts, *low)
+		}
+	}
+
+	created, err := findOne(ctx, tx, strconv.Itoa(nt.Number), note.Options{})
+	if err != nil {
+		return err
+	}
+	invoice := created.Summary.GrandTotal
+	if created.Quote != nil {
+		invoice = created.Quote.GrandTotal
+	}
+	err = r.checkCreditLimit(ctx, tx, nt.BuyerID, nt.Number, invoice, nt.OverrideCreditLimit)
+	if err != nil {
+		return err
+	}
+
+	if err = audit.Log(ctx, tx, audit.EntityNote, nt.Number, audit.ActionCreate, nil); err != nil {
+		return r.wrapError(err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
 		return err
 	}
+	r.alerter.LowStock(alerts...)
 
 	return nil
 }
 
-func (r *repository) FindAll(ctx context.Context) ([]note.NoteWithPrdList, error) {
-	qNote := `
+// checkCreditLimit refuses an invoice that would take the unpaid total of the
+// buyer over their credit limit. The buyer row stays locked until the
+// transaction ends, so invoices of the same buyer are checked one at a time.
+// Only confirmed and paid notes count, with their own payments: money left on
+// cancelled notes and points paid on drafts do not lower the unpaid total.
+// An overridden limit is recorded on the note for audit.
+func (r *repository) checkCreditLimit(ctx context.Context, client postgresql.Client, buyerID, number int, invoice money.Money, override bool) error {
+	q := `
+		SELECT
+		    credit_limit, deleted_at
+		FROM
+		    public.buyer
+		WHERE id = $1
+		FOR UPDATE
+	`
+
+	var limit *money.Money
+	var deletedAt *time.Time
+	err := client.QueryRow(ctx, q, buyerID).Scan(&limit, &deletedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return apperror.BadRequestError(fmt.Sprintf("buyer %d does not exist", buyerID))
+	}
+	if err != nil {
+		return r.wrapError(err)
+	}
+	if deletedAt != nil {
+		return apperror.BadRequestError(fmt.Sprintf("buyer %d is deleted", buyerID))
+	}
+	if limit == nil {
+		return nil
+	}
+
+	q = `
 		SELECT
-		    number, date, buyer_id
+		    COALESCE(SUM(gross - returned - paid) FILTER (WHERE status IN ('confirmed', 'paid')), 0)
 		FROM
+		    public.note_balance
+		WHERE buyer_id = $1 AND number <> $2
+	`
+
+	var unpaid money.Money
+	if err = client.QueryRow(ctx, q, buyerID, number).Scan(&unpaid); err != nil {
+		return r.wrapError(err)
+	}
+	if unpaid.Add(invoice).Cmp(*limit) <= 0 {
+		return nil
+	}
+
+	if !override {
+		return apperror.CreditLimitError(fmt.Sprintf(
+			"buyer %d owes %s, note %d of %s would exceed the credit limit of %s",
+			buyerID, unpaid, number, invoice, *limit,
+		))
+	}
+
+	r.logger.Info.Printf("credit limit of buyer %d overridden for note %d", buyerID, number)
+	q = `
+		UPDATE
 		    public.note
+		SET
+		    credit_limit_override = true,
+		    credit_limit_override_at = now()
+		WHERE
+		    number = $1
+	`
+	if _, err = client.Exec(ctx, q, number); err != nil {
+		return r.wrapError(err)
+	}
+
+	return nil
+}
+
+// noteQuery selects notes together with their buyer and a summary of their
+// line items. The summary is aggregated by the database from the
+// product_list_total view, so clients and Go code never have to add prices
+// up themselves.
+const noteQuery = `
+		SELECT
+		    n.number, n.invoice_number, n.date, n.buyer_id, n.status, n.warehouse_id, n.credit_limit_override, n.deleted_at,
+		    b.id, b.name, b.surname,
+		    COALESCE(s.subtotal, 0), COALESCE(s.total_quantity, 0), s.line_count,
+		    COALESCE(s.discount, 0), COALESCE(s.net, 0), COALESCE(s.tax, 0), COALESCE(s.gross, 0),
+		    -nb.returned, nb.paid, nb.outstanding
+		FROM
+		    public.note AS n
+		    INNER JOIN public.note_balance AS nb ON nb.number = n.number
+		    LEFT JOIN public.buyer AS b ON b.id = n.buyer_id
+		    LEFT JOIN LATERAL (
+		        SELECT
+		            SUM(plt.subtotal) AS subtotal,
+		            SUM(plt.amount) AS total_quantity,
+		            COUNT(*) AS line_count,
+		            SUM(plt.discount) AS discount,
+		            SUM(plt.net) AS net,
+		            SUM(plt.tax) AS tax,
+		            SUM(plt.gross) AS gross
+		        FROM
+		            public.product_list_total AS plt
+		        WHERE plt.note_id = n.number
+		    ) AS s ON true
 	`
 
-	rowsQNote, err := r.client.Query(ctx, qNote)
+func scanNote(row pgx.Row, opts note.Options) (note.NoteWithPrdList, error) {
+	var nt note.NoteWithPrdList
+	var buyerID *int
+	var buyerName, buyerSurname *string
+
+	err := row.Scan(
+		&nt.Number, &nt.InvoiceNumber, &nt.Date, &nt.BuyerID, &nt.Status, &nt.WarehouseID, &nt.CreditLimitOverride, &nt.DeletedAt,
+		&buyerID, &buyerName, &buyerSurname,
+		&nt.Summary.Subtotal, &nt.Summary.TotalQuantity, &nt.Summary.LineCount,
+		&nt.Summary.Discount, &nt.Summary.Net, &nt.Summary.Tax, &nt.Summary.GrandTotal,
+		&nt.Summary.Returned, &nt.Summary.Paid, &nt.Summary.Outstanding,
+	)
 	if err != nil {
-		return nil, err
+		return note.NoteWithPrdList{}, err
 	}
 
-	notes := make([]note.NoteWithPrdList, 0)
+	if opts.WithBuyer && buyerID != nil {
+		nt.Buyer = &buyer.Buyer{ID: *buyerID, Name: *buyerName, Surname: *buyerSurname}
+	}
+	nt.PrdLists = make([]note.PrdList, 0)
 
-	for rowsQNote.Next() {
-		var nt note.NoteWithPrdList
+	return nt, nil
+}
 
-		err = rowsQNote.Scan(&nt.Number, &nt.Date, &nt.BuyerID)
-		if err != nil {
-			return nil, err
-		}
+// attachPrdLists loads the line items of all the given notes with one query.
+func attachPrdLists(ctx context.Context, client postgresql.Client, notes []note.NoteWithPrdList) error {
+	if len(notes) == 0 {
+		return nil
+	}
+
+	byNumber := make(map[int]*note.NoteWithPrdList, len(notes))
+	numbers := make([]int, 0, len(notes))
+	for i := range notes {
+		byNumber[notes[i].Number] = &notes[i]
+		numbers = append(numbers, notes[i].Number)
+	}
 
-		qPrdList := `
+	q := `
 		SELECT
-    		product.name, product.price, product_list.amount
+    		note_id, id, product_id, name, price, amount, price * amount, tax_rate, tax_inclusive
 		FROM
-    		product_list INNER JOIN product ON product_list.product_id = product.id
-		WHERE note_id = $1;
+    		public.product_list
+		WHERE note_id = ANY($1) AND deleted_at IS NULL
+		ORDER BY note_id, id
 	`
 
-		rows, err := r.client.Query(ctx, qPrdList, nt.Number)
+	rows, err := client.Query(ctx, q, numbers)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var noteID int
+		var list note.PrdList
+
+		err = rows.Scan(&noteID, &list.ID, &list.ProductID, &list.Name, &list.Price, &list.Amount, &list.TotalCount, &list.TaxRate, &list.TaxInclusive)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		lists := make([]note.PrdList, 0)
+		list.Discounts = make([]promotion.Discount, 0)
+		list.Total = list.TotalCount
+
+		nt := byNumber[noteID]
+		nt.PrdLists = append(nt.PrdLists, list)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	if err = attachDiscounts(ctx, client, notes); err != nil {
+		return err
+	}
+
+	for i := range notes {
+		applyTaxes(&notes[i])
+	}
+
+	return nil
+}
+
+// attachDiscounts itemises discounts on the line items of the notes. Stored
+// discounts are used for notes that left the draft status; drafts get a fresh
+// quote from the promotion engine.
+func attachDiscounts(ctx context.Context, client postgresql.Client, notes []note.NoteWithPrdList) error {
+	lines := make(map[int]*note.PrdList)
+	numbers := make([]int, 0, len(notes))
+	drafts := make([]*note.NoteWithPrdList, 0)
+	for i := range notes {
+		if notes[i].Status == note.StatusDraft {
+			drafts = append(drafts, &notes[i])
+			continue
+		}
+
+		numbers = append(numbers, notes[i].Number)
+		for j := range notes[i].PrdLists {
+			lines[notes[i].PrdLists[j].ID] = &notes[i].PrdLists[j]
+		}
+	}
+
+	if len(numbers) > 0 {
+		q := `
+			SELECT
+			    pld.product_list_id, COALESCE(pld.promotion_id, 0), pld.name, pld.amount
+			FROM
+			    public.product_list_discount AS pld
+			    INNER JOIN public.product_list AS pl ON pl.id = pld.product_list_id
+			WHERE pl.note_id = ANY($1) AND pl.deleted_at IS NULL
+			ORDER BY pld.id
+		`
+
+		rows, err := client.Query(ctx, q, numbers)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
 
 		for rows.Next() {
-			var list note.PrdList
+			var productListID int
+			var discount promotion.Discount
 
-			err = rows.Scan(&list.Name, &list.Price, &list.Amount)
+			err = rows.Scan(&productListID, &discount.PromotionID, &discount.Name, &discount.Amount)
 			if err != nil {
-				return nil, err
+				return err
 			}
 
-			list.TotalCount = list.Price * float64(list.Amount)
-			lists = append(lists, list)
+			if list, ok := lines[productListID]; ok {
+				addDiscount(list, discount)
+			}
 		}
 
 		if err = rows.Err(); err != nil {
-			return nil, err
+			return err
+		}
+		rows.Close()
+	}
+
+	if len(drafts) == 0 {
+		return nil
+	}
+
+	promotions, err := promotionDB.List(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	for _, nt := range drafts {
+		discounts := promotion.Apply(promotions, engineLines(nt.PrdLists), nt.Date)
+		for i := range nt.PrdLists {
+			for _, discount := range discounts[i] {
+				addDiscount(&nt.PrdLists[i], discount)
+			}
+		}
+	}
+
+	return nil
+}
+
+// applyTaxes splits every line of the note into net, tax and gross parts and
+// groups them by tax rate. Drafts get a quote with the discounts the database
+// does not know of yet; their summary is left as the database computed it.
+func applyTaxes(nt *note.NoteWithPrdList) {
+	nt.Taxes = make([]note.TaxGroup, 0)
+	groups := make(map[int64]int)
+
+	var discount, net, vat, gross money.Money
+	for i := range nt.PrdLists {
+		list := &nt.PrdLists[i]
+		list.Net, list.Tax, list.Gross = tax.Split(list.Total, list.TaxRate, list.TaxInclusive)
+
+		g, ok := groups[list.TaxRate.Cents()]
+		if !ok {
+			g = len(nt.Taxes)
+			groups[list.TaxRate.Cents()] = g
+			nt.Taxes = append(nt.Taxes, note.TaxGroup{Rate: list.TaxRate})
+		}
+		nt.Taxes[g].Net = nt.Taxes[g].Net.Add(list.Net)
+		nt.Taxes[g].Tax = nt.Taxes[g].Tax.Add(list.Tax)
+		nt.Taxes[g].Gross = nt.Taxes[g].Gross.Add(list.Gross)
+
+		discount = discount.Add(list.Discount)
+		net = net.Add(list.Net)
+		vat = vat.Add(list.Tax)
+		gross = gross.Add(list.Gross)
+	}
+
+	if nt.Status == note.StatusDraft {
+		nt.Quote = &note.Quote{
+			Discount:    discount,
+			Net:         net,
+			Tax:         vat,
+			GrandTotal:  gross,
+			Outstanding: gross.Add(nt.Summary.Returned).Sub(nt.Summary.Paid),
 		}
+	}
+}
 
-		nt.PrdLists = lists
+func addDiscount(list *note.PrdList, discount promotion.Discount) {
+	list.Discounts = append(list.Discounts, discount)
+	list.Discount = list.Discount.Add(discount.Amount)
+	list.Total = list.TotalCount.Sub(list.Discount)
+}
+
+func engineLines(lists []note.PrdList) []promotion.Line {
+	lines := make([]promotion.Line, 0, len(lists))
+	for _, list := range lists {
+		lines = append(lines, promotion.Line{ProductID: list.ProductID, Price: list.Price, Amount: list.Amount})
+	}
+	return lines
+}
+
+func (r *repository) FindAll(ctx context.Context, filter note.Filter, opts note.Options) ([]note.NoteWithPrdList, error) {
+	q := noteQuery + `
+		WHERE ($1::text = '' OR n.invoice_number ILIKE '%' || $1 || '%')
+		    AND ($2 OR n.deleted_at IS NULL)
+		ORDER BY n.number
+	`
+
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q, filter.InvoiceNumber, filter.IncludeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := make([]note.NoteWithPrdList, 0)
+
+	for rows.Next() {
+		nt, err := scanNote(rows, opts)
+		if err != nil {
+			return nil, err
+		}
 
 		notes = append(notes, nt)
 	}
 
-	if err = rowsQNote.Err(); err != nil {
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err = attachPrdLists(ctx, r.client, notes); err != nil {
 		return nil, err
 	}
 
 	return notes, nil
 }
 
-func (r *repository) FindOne(ctx context.Context, number string) (note.NoteWithPrdList, error) {
-	qNote := `
+// FindByBuyer returns a page of the notes of a buyer, newest first, with
+// statistics over the whole purchase history of the buyer.
+func (r *repository) FindByBuyer(ctx context.Context, buyerID string, filter note.HistoryFilter, opts note.Options) (note.BuyerHistory, error) {
+	history := note.BuyerHistory{
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+		Notes:  make([]note.NoteWithPrdList, 0),
+	}
+
+	q := `
 		SELECT
-		    number, date, buyer_id
+		    b.id,
+		    COUNT(nb.number) FILTER (WHERE nb.status IN ('confirmed', 'paid')),
+		    COALESCE(SUM(nb.gross - nb.returned) FILTER (WHERE nb.status IN ('confirmed', 'paid')), 0),
+		    MIN(n.date) FILTER (WHERE nb.status IN ('confirmed', 'paid')),
+		    MAX(n.date) FILTER (WHERE nb.status IN ('confirmed', 'paid'))
 		FROM
-		    public.note
-		WHERE number = $1
+		    public.buyer AS b
+		    LEFT JOIN public.note AS n ON n.buyer_id = b.id
+		    LEFT JOIN public.note_balance AS nb ON nb.number = n.number
+		WHERE b.id = $1
+		GROUP BY b.id
 	`
-	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(qNote)))
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
 
-	var nt note.NoteWithPrdList
-	err := r.client.QueryRow(ctx, qNote, number).Scan(&nt.Number, &nt.Date, &nt.BuyerID)
+	stats := &history.Stats
+	err := r.client.QueryRow(ctx, q, buyerID).Scan(
+		&history.BuyerID, &stats.NotesCount, &stats.TotalSpent, &stats.FirstPurchase, &stats.LastPurchase,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return note.BuyerHistory{}, apperror.ErrNotFound
+	}
 	if err != nil {
-		return note.NoteWithPrdList{}, err
+		return note.BuyerHistory{}, r.wrapError(err)
+	}
+
+	if stats.FavouriteProducts, err = r.favouriteProducts(ctx, history.BuyerID); err != nil {
+		return note.BuyerHistory{}, err
 	}
 
-	qPrdList := `
+	q = `
 		SELECT
-    		product.name, product.price, product_list.amount
+		    COUNT(*)
 		FROM
-    		product_list INNER JOIN product ON product_list.product_id = product.id
-		WHERE note_id = $1;
+		    public.note AS n
+		WHERE n.buyer_id = $1 AND n.deleted_at IS NULL
+		    AND ($2::timestamp IS NULL OR n.date >= $2)
+		    AND ($3::timestamp IS NULL OR n.date < $3)
 	`
-
-	rows, err := r.client.Query(ctx, qPrdList, nt.Number)
+	err = r.client.QueryRow(ctx, q, history.BuyerID, filter.Period.From, filter.Period.To).Scan(&history.Total)
 	if err != nil {
-		return note.NoteWithPrdList{}, err
+		return note.BuyerHistory{}, r.wrapError(err)
 	}
 
-	lists := make([]note.PrdList, 0)
+	q = noteQuery + `
+		WHERE n.buyer_id = $1 AND n.deleted_at IS NULL
+		    AND ($2::timestamp IS NULL OR n.date >= $2)
+		    AND ($3::timestamp IS NULL OR n.date < $3)
+		ORDER BY n.date DESC, n.number DESC
+		LIMIT $4 OFFSET $5
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
 
-	for rows.Next() {
-		var list note.PrdList
+	rows, err := r.client.Query(ctx, q,
+		history.BuyerID, filter.Period.From, filter.Period.To, filter.Limit, filter.Offset,
+	)
+	if err != nil {
+		return note.BuyerHistory{}, r.wrapError(err)
+	}
+	defer rows.Close()
 
-		err = rows.Scan(&list.Name, &list.Price, &list.Amount)
+	for rows.Next() {
+		nt, err := scanNote(rows, opts)
 		if err != nil {
-			return note.NoteWithPrdList{}, err
+			return note.BuyerHistory{}, err
 		}
 
-		list.TotalCount = list.Price * float64(list.Amount)
-		lists = append(lists, list)
+		history.Notes = append(history.Notes, nt)
 	}
 
 	if err = rows.Err(); err != nil {
+		return note.BuyerHistory{}, err
+	}
+	rows.Close()
+
+	if err = attachPrdLists(ctx, r.client, history.Notes); err != nil {
+		return note.BuyerHistory{}, err
+	}
+
+	return history, nil
+}
+
+// favouriteProductsLimit is how many favourite products are listed in the
+// statistics of a buyer.
+const favouriteProductsLimit = 5
+
+// favouriteProducts ranks the products of the confirmed and paid notes of a
+// buyer by the quantity the buyer kept, that is bought less returned.
+func (r *repository) favouriteProducts(ctx context.Context, buyerID int) ([]note.FavouriteProduct, error) {
+	q := `
+		SELECT
+		    plt.product_id,
+		    (ARRAY_AGG(pl.name ORDER BY pl.id DESC))[1],
+		    SUM(plt.amount - COALESCE(ret.amount, 0)),
+		    SUM(plt.gross - COALESCE(ret.gross, 0))
+		FROM
+		    public.product_list_total AS plt
+		    INNER JOIN public.product_list AS pl ON pl.id = plt.id
+		    INNER JOIN public.note AS n ON n.number = plt.note_id
+		    LEFT JOIN LATERAL (
+		        SELECT SUM(cl.amount) AS amount, SUM(cl.gross) AS gross
+		        FROM public.credit_note_line AS cl
+		        WHERE cl.product_list_id = plt.id
+		    ) AS ret ON true
+		WHERE n.buyer_id = $1 AND n.status IN ('confirmed', 'paid')
+		GROUP BY plt.product_id
+		HAVING SUM(plt.amount - COALESCE(ret.amount, 0)) > 0
+		ORDER BY 3 DESC, 4 DESC, plt.product_id
+		LIMIT $2
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q, buyerID, favouriteProductsLimit)
+	if err != nil {
+		return nil, r.wrapError(err)
+	}
+	defer rows.Close()
+
+	products := make([]note.FavouriteProduct, 0)
+	for rows.Next() {
+		var fp note.FavouriteProduct
+		if err = rows.Scan(&fp.ProductID, &fp.Name, &fp.Amount, &fp.Total); err != nil {
+			return nil, err
+		}
+		products = append(products, fp)
+	}
+
+	return products, rows.Err()
+}
+
+func (r *repository) FindOne(ctx context.Context, number string, opts note.Options) (note.NoteWithPrdList, error) {
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(noteByNumberQuery)))
+
+	return findOne(ctx, r.client, number, opts)
+}
+
+const noteByNumberQuery = noteQuery + `
+		WHERE n.number = $1
+	`
+
+// findOne loads a single note through the given client, which may be a
+// transaction that has not been committed yet.
+func findOne(ctx context.Context, client postgresql.Client, number string, opts note.Options) (note.NoteWithPrdList, error) {
+	nt, err := scanNote(client.QueryRow(ctx, noteByNumberQuery, number), opts)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return note.NoteWithPrdList{}, apperror.ErrNotFound
+	}
+	if err != nil {
 		return note.NoteWithPrdList{}, err
 	}
 
-	nt.PrdLists = lists
+	notes := []note.NoteWithPrdList{nt}
+	if err = attachPrdLists(ctx, client, notes); err != nil {
+		return note.NoteWithPrdList{}, err
+	}
 
-	return nt, nil
+	return notes[0], nil
 }
 
-func (r *repository) Update(ctx context.Context, note note.Note) error {
+// Update changes the date and buyer of a draft. Once a note is confirmed its
+// debt, credit limit check and loyalty points belong to its buyer, so it can
+// only be cancelled.
+func (r *repository) Update(ctx context.Context, nt note.Note) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
 	q := `
+		SELECT
+		    status, buyer_id
+		FROM
+		    public.note
+		WHERE number = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`
+
+	var status note.Status
+	var buyerID *int
+	err = tx.QueryRow(ctx, q, nt.Number).Scan(&status, &buyerID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		newErr := errors.New("no row found to update")
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+	if err != nil {
+		return r.wrapError(err)
+	}
+	if !status.Editable() {
+		return apperror.ConflictError(fmt.Sprintf("note %d is %s, only drafts can be changed", nt.Number, status))
+	}
+
+	before, err := audit.Snapshot(ctx, tx, audit.EntityNote, nt.Number)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	q = `
 		UPDATE 
     		public.note
 		SET
@@ -172,52 +681,410 @@ func (r *repository) Update(ctx context.Context, note note.Note) error {
 		    number = $3
 	`
 
-	commandTag, err := r.client.Exec(ctx, q, note.Date, note.BuyerID, note.Number)
+	if _, err = tx.Exec(ctx, q, nt.Date, nt.BuyerID, nt.Number); err != nil {
+		return r.wrapError(err)
+	}
+
+	// The new buyer has to be able to take the note on, as when it was
+	// created. Points paid on the draft came from the old buyer, who only
+	// gets them back when the note is cancelled.
+	if buyerID == nil || *buyerID != nt.BuyerID {
+		var paidWithPoints bool
+		q = `SELECT EXISTS (SELECT 1 FROM public.payment WHERE note_id = $1 AND method = 'points')`
+		if err = tx.QueryRow(ctx, q, nt.Number).Scan(&paidWithPoints); err != nil {
+			return r.wrapError(err)
+		}
+		if paidWithPoints {
+			return apperror.ConflictError(fmt.Sprintf("note %d is paid with the points of its buyer, its buyer can not be changed", nt.Number))
+		}
+
+		updated, err := findOne(ctx, tx, strconv.Itoa(nt.Number), note.Options{})
+		if err != nil {
+			return err
+		}
+		err = r.checkCreditLimit(ctx, tx, nt.BuyerID, nt.Number, updated.Quote.GrandTotal, nt.OverrideCreditLimit)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = audit.Log(ctx, tx, audit.EntityNote, nt.Number, audit.ActionUpdate, before); err != nil {
+		return r.wrapError(err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Delete marks a note as deleted. Only cancelled notes and drafts without line
+// items or payments can be deleted, so that a deleted note holds no stock,
+// owes nothing and keeps no points; a confirmed note has to be cancelled
+// first.
+func (r *repository) Delete(ctx context.Context, number string) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	q := `
+		SELECT
+		    n.number, n.status,
+		    EXISTS (SELECT 1 FROM public.product_list AS pl WHERE pl.note_id = n.number AND pl.deleted_at IS NULL)
+		        OR EXISTS (SELECT 1 FROM public.payment AS pm WHERE pm.note_id = n.number)
+		FROM
+		    public.note AS n
+		WHERE n.number = $1 AND n.deleted_at IS NULL
+		FOR UPDATE OF n
+	`
+
+	var noteNumber int
+	var status note.Status
+	var inUse bool
+	err = tx.QueryRow(ctx, q, number).Scan(&noteNumber, &status, &inUse)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return apperror.ErrNotFound
+	}
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	if status != note.StatusCancelled && (status != note.StatusDraft || inUse) {
+		return apperror.ConflictError(fmt.Sprintf("note %s is %s, only cancelled notes and drafts without line items or payments can be deleted", number, status))
+	}
+
+	before, err := audit.Snapshot(ctx, tx, audit.EntityNote, noteNumber)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	q = `UPDATE public.note SET deleted_at = now() WHERE number = $1`
+	if _, err = tx.Exec(ctx, q, noteNumber); err != nil {
+		return r.wrapError(err)
+	}
+
+	if err = audit.Log(ctx, tx, audit.EntityNote, noteNumber, audit.ActionDelete, before); err != nil {
+		return r.wrapError(err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *repository) Restore(ctx context.Context, number string) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := audit.Snapshot(ctx, tx, audit.EntityNote, number)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	q := `UPDATE public.note SET deleted_at = NULL WHERE number = $1 AND deleted_at IS NOT NULL RETURNING number`
+	var noteNumber int
+	err = tx.QueryRow(ctx, q, number).Scan(&noteNumber)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return apperror.ErrNotFound
+	}
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	if err = audit.Log(ctx, tx, audit.EntityNote, noteNumber, audit.ActionRestore, before); err != nil {
+		return r.wrapError(err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *repository) Transition(ctx context.Context, number string, to note.Status, overrideCreditLimit bool) error {
+	tx, err := r.client.Begin(ctx)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			pgErr = err.(*pgconn.PgError)
-			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
-			r.logger.Err.Println(newErr)
-			return newErr
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	q := `
+		SELECT
+		    number, status, date, buyer_id
+		FROM
+		    public.note
+		WHERE number = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`
+
+	var noteNumber int
+	var buyerID *int
+	var current note.Status
+	var date time.Time
+	err = tx.QueryRow(ctx, q, number).Scan(&noteNumber, &current, &date, &buyerID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return apperror.ErrNotFound
+	}
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	if !current.CanTransitionTo(to) {
+		return apperror.ConflictError(fmt.Sprintf("note can not be moved from %s to %s", current, to))
+	}
+
+	// A note is only paid once its payments cover it; they move it to paid
+	// themselves, so this is left for notes settled some other way.
+	if to == note.StatusPaid {
+		var outstanding money.Money
+		q = `SELECT outstanding FROM public.note_balance WHERE number = $1`
+		if err = tx.QueryRow(ctx, q, noteNumber).Scan(&outstanding); err != nil {
+			return r.wrapError(err)
+		}
+		if outstanding.Cmp(money.Money{}) > 0 {
+			return apperror.ConflictError(fmt.Sprintf("note %d still has %s outstanding, record a payment instead", noteNumber, outstanding))
 		}
+	}
+
+	before, err := audit.Snapshot(ctx, tx, audit.EntityNote, noteNumber)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	if to == note.StatusConfirmed {
+		if err = storeDiscounts(ctx, tx, number, date); err != nil {
+			return r.wrapError(err)
+		}
+
+		var gross, paid, outstanding money.Money
+		q = `SELECT gross, paid, outstanding FROM public.note_balance WHERE number = $1`
+		if err = tx.QueryRow(ctx, q, noteNumber).Scan(&gross, &paid, &outstanding); err != nil {
+			return r.wrapError(err)
+		}
+		// Points paid on the draft were checked against its quote, which may
+		// have gone down since with its promotions or line items.
+		if outstanding.IsNegative() {
+			return apperror.ConflictError(fmt.Sprintf(
+				"note %d: %s paid in points is more than its total of %s, cancel it to give the points back",
+				noteNumber, paid, gross,
+			))
+		}
+
+		if buyerID != nil {
+			err = r.checkCreditLimit(ctx, tx, *buyerID, noteNumber, outstanding, overrideCreditLimit)
+			if err != nil {
+				return err
+			}
+			if err = r.loyalty.Earn(ctx, tx, *buyerID, noteNumber, gross); err != nil {
+				return r.wrapError(err)
+			}
+		}
+
+		if err = r.assignInvoiceNumber(ctx, tx, noteNumber, date.Year()); err != nil {
+			return r.wrapError(err)
+		}
+
+		// A draft paid in full with points is settled as soon as it is
+		// confirmed, as it would be by its last payment.
+		if !paid.IsZero() && outstanding.IsZero() {
+			to = note.StatusPaid
+		}
+	}
+	if to == note.StatusCancelled {
+		if err = releaseStock(ctx, tx, number); err != nil {
+			return r.wrapError(err)
+		}
+		if err = loyalty.ReverseNote(ctx, tx, noteNumber); err != nil {
+			return r.wrapError(err)
+		}
+	}
+
+	q = `
+		UPDATE 
+    		public.note
+		SET
+			status = $1
+		WHERE
+		    number = $2
+	`
+	if _, err = tx.Exec(ctx, q, to, number); err != nil {
+		return r.wrapError(err)
+	}
+
+	if err = audit.Log(ctx, tx, audit.EntityNote, noteNumber, audit.ActionUpdate, before); err != nil {
+		return r.wrapError(err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Outstanding is what is left to pay on a note. A draft is not invoiced yet,
+// so what it owes is worked out from its quote.
+func Outstanding(ctx context.Context, client postgresql.Client, number int) (money.Money, error) {
+	nt, err := findOne(ctx, client, strconv.Itoa(number), note.Options{})
+	if err != nil {
+		return money.Money{}, err
+	}
+	if nt.Quote != nil {
+		return nt.Quote.Outstanding, nil
+	}
+	return nt.Summary.Outstanding, nil
+}
+
+// assignInvoiceNumber gives a confirmed note the next invoice number of the
+// year the note is dated, which is the year printed on the invoice, even when
+// it is confirmed later. The counter row stays locked until the confirmation
+// commits and goes back with it when it rolls back, so the numbers have no
+// gaps.
+func (r *repository) assignInvoiceNumber(ctx context.Context, client postgresql.Client, number, year int) error {
+	q := `
+		INSERT INTO public.invoice_sequence
+		    (year, last_value)
+		VALUES
+		       ($1, 1)
+		ON CONFLICT (year) DO UPDATE SET last_value = invoice_sequence.last_value + 1
+		RETURNING last_value
+	`
+
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+
+	var sequence int
+	if err := client.QueryRow(ctx, q, year).Scan(&sequence); err != nil {
 		return err
 	}
-	if commandTag.RowsAffected() != 1 {
-		newErr := errors.New("no row found to update")
-		r.logger.Err.Println(newErr)
-		return newErr
+
+	q = `UPDATE public.note SET invoice_number = $1 WHERE number = $2`
+	_, err := client.Exec(ctx, q, r.numbering.Format(year, sequence), number)
+	return err
+}
+
+// storeDiscounts applies the promotions running at the note date to its line
+// items and stores the result, so the confirmed invoice keeps its discounts
+// even when promotions change later.
+func storeDiscounts(ctx context.Context, client postgresql.Client, number string, date time.Time) error {
+	q := `
+		SELECT
+		    id, product_id, price, amount
+		FROM
+		    public.product_list
+		WHERE note_id = $1 AND deleted_at IS NULL
+		ORDER BY id
+	`
+
+	rows, err := client.Query(ctx, q, number)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	lists := make([]note.PrdList, 0)
+	for rows.Next() {
+		var list note.PrdList
+		if err = rows.Scan(&list.ID, &list.ProductID, &list.Price, &list.Amount); err != nil {
+			return err
+		}
+		lists = append(lists, list)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	promotions, err := promotionDB.List(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	q = `
+		INSERT INTO public.product_list_discount 
+		    (product_list_id, promotion_id, name, amount) 
+		VALUES 
+		       ($1, $2, $3, $4)
+	`
+	for i, discounts := range promotion.Apply(promotions, engineLines(lists), date) {
+		for _, discount := range discounts {
+			if _, err = client.Exec(ctx, q, lists[i].ID, discount.PromotionID, discount.Name, discount.Amount); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
-func (r *repository) Delete(ctx context.Context, number string) error {
-	q := `DELETE FROM public.note WHERE number = $1`
-	commandTag, err := r.client.Exec(ctx, q, number)
+// releaseStock puts every line item of a note back into stock, except for
+// the units that have already come back with credit notes.
+func releaseStock(ctx context.Context, client postgresql.Client, number string) error {
+	q := `
+		SELECT
+		    pl.id, pl.note_id, n.warehouse_id, pl.product_id,
+		    pl.amount - COALESCE((SELECT SUM(cl.amount) FROM public.credit_note_line AS cl WHERE cl.product_list_id = pl.id), 0)
+		FROM
+		    public.product_list AS pl
+		    INNER JOIN public.note AS n ON n.number = pl.note_id
+		WHERE pl.note_id = $1 AND pl.deleted_at IS NULL
+	`
+
+	rows, err := client.Query(ctx, q, number)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			pgErr = err.(*pgconn.PgError)
-			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
-			r.logger.Err.Println(newErr)
-			return newErr
+		return err
+	}
+	defer rows.Close()
+
+	var warehouseID int
+	lines := make([]prdlist.ProductList, 0)
+	for rows.Next() {
+		var pl prdlist.ProductList
+		if err = rows.Scan(&pl.ID, &pl.NoteID, &warehouseID, &pl.ProductID, &pl.Amount); err != nil {
+			return err
 		}
+		lines = append(lines, pl)
+	}
+	if err = rows.Err(); err != nil {
 		return err
 	}
+	rows.Close()
 
-	if commandTag.RowsAffected() != 1 {
-		newErr := errors.New("no row found to delete")
-		r.logger.Err.Println(newErr)
-		return newErr
+	productIDs := make([]int, 0, len(lines))
+	for _, pl := range lines {
+		productIDs = append(productIDs, pl.ProductID)
+	}
+	if err = stock.Lock(ctx, client, productIDs...); err != nil {
+		return err
+	}
+
+	for _, pl := range lines {
+		if pl.Amount == 0 {
+			continue
+		}
+		ref := stock.Ref{
+			Kind:          stock.KindSale,
+			Reason:        "note cancelled",
+			WarehouseID:   warehouseID,
+			NoteID:        pl.NoteID,
+			ProductListID: pl.ID,
+		}
+		if err = stock.Release(ctx, client, pl.ProductID, pl.Amount, ref); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func NewRepository(client postgresql.Client, logger *logging.Logger) note.Repository {
+func (r *repository) wrapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+	return err
+}
+
+func NewRepository(client postgresql.Client, logger *logging.Logger, alerter *stock.Alerter, program *loyalty.Program, numbering note.Numbering) note.Repository {
 	return &repository{
-		client: client,
-		logger: logger,
+		client:    client,
+		logger:    logger,
+		alerter:   alerter,
+		loyalty:   program,
+		numbering: numbering,
 	}
 }
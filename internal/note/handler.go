@@ -8,12 +8,19 @@ import (
 	"restapi-lesson/internal/apperror"
 	"restapi-lesson/internal/handlers"
 	"restapi-lesson/internal/logging"
+	"restapi-lesson/pkg/daterange"
 	"strconv"
+	"strings"
 )
 
 const (
-	notesURL = "/notes"
-	noteURL  = "/notes/:uuid"
+	notesURL       = "/notes"
+	noteURL        = "/notes/:uuid"
+	noteConfirmURL = "/notes/:uuid/confirm"
+	notePayURL     = "/notes/:uuid/pay"
+	noteCancelURL  = "/notes/:uuid/cancel"
+	noteRestoreURL = "/notes/:uuid/restore"
+	buyerNotesURL  = "/buyers/:uuid/notes"
 )
 
 type handler struct {
@@ -34,6 +41,24 @@ func (h *handler) Register(router *httprouter.Router) {
 	router.HandlerFunc(http.MethodPost, notesURL, apperror.Middleware(h.CreateNote))
 	router.HandlerFunc(http.MethodPatch, noteURL, apperror.Middleware(h.UpdateNote))
 	router.HandlerFunc(http.MethodDelete, noteURL, apperror.Middleware(h.DeleteNote))
+	router.HandlerFunc(http.MethodPost, noteConfirmURL, apperror.Middleware(h.ConfirmNote))
+	router.HandlerFunc(http.MethodPost, notePayURL, apperror.Middleware(h.PayNote))
+	router.HandlerFunc(http.MethodPost, noteCancelURL, apperror.Middleware(h.CancelNote))
+	router.HandlerFunc(http.MethodPost, noteRestoreURL, apperror.Middleware(h.RestoreNote))
+	router.HandlerFunc(http.MethodGet, buyerNotesURL, apperror.Middleware(h.GetBuyerNotes))
+}
+
+// optionsFromQuery reads the comma separated include query parameter,
+// e.g. /notes/1?include=buyer.
+func optionsFromQuery(r *http.Request) Options {
+	var opts Options
+	for _, include := range strings.Split(r.URL.Query().Get("include"), ",") {
+		switch strings.TrimSpace(include) {
+		case "buyer":
+			opts.WithBuyer = true
+		}
+	}
+	return opts
 }
 
 func (h *handler) GetNote(w http.ResponseWriter, r *http.Request) error {
@@ -47,7 +72,7 @@ func (h *handler) GetNote(w http.ResponseWriter, r *http.Request) error {
 		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
 	}
 
-	note, err := h.repository.FindOne(r.Context(), noteUUID)
+	note, err := h.repository.FindOne(r.Context(), noteUUID, optionsFromQuery(r))
 	if err != nil {
 		return err
 	}
@@ -69,7 +94,11 @@ func (h *handler) GetAllNotes(w http.ResponseWriter, r *http.Request) error {
 
 	h.logger.Info.Println("get category_uuid from URL")
 
-	notes, err := h.repository.FindAll(r.Context())
+	filter := Filter{
+		InvoiceNumber:  strings.TrimSpace(r.URL.Query().Get("invoice_number")),
+		IncludeDeleted: r.URL.Query().Get("include_deleted") == "true",
+	}
+	notes, err := h.repository.FindAll(r.Context(), filter, optionsFromQuery(r))
 	if err != nil {
 		return err
 	}
@@ -102,8 +131,19 @@ func (h *handler) CreateNote(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	noteNumber := nt.Number
+	created, err := h.repository.FindOne(r.Context(), strconv.Itoa(noteNumber), optionsFromQuery(r))
+	if err != nil {
+		return err
+	}
+
+	noteBytes, err := json.Marshal(created)
+	if err != nil {
+		return err
+	}
+
 	w.Header().Set("Location", fmt.Sprintf("%s/%v", notesURL, noteNumber))
 	w.WriteHeader(http.StatusCreated)
+	w.Write(noteBytes)
 
 	return nil
 }
@@ -159,3 +199,139 @@ func (h *handler) DeleteNote(w http.ResponseWriter, r *http.Request) error {
 
 	return nil
 }
+
+func (h *handler) ConfirmNote(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("CONFIRM NOTE")
+	return h.changeStatus(w, r, StatusConfirmed, r.URL.Query().Get("override_credit_limit") == "true")
+}
+
+func (h *handler) PayNote(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("PAY NOTE")
+	return h.changeStatus(w, r, StatusPaid, false)
+}
+
+func (h *handler) CancelNote(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("CANCEL NOTE")
+	return h.changeStatus(w, r, StatusCancelled, false)
+}
+
+func (h *handler) changeStatus(w http.ResponseWriter, r *http.Request, to Status, overrideCreditLimit bool) error {
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	noteNumber := params.ByName("uuid")
+	if noteNumber == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+
+	err := h.repository.Transition(r.Context(), noteNumber, to, overrideCreditLimit)
+	if err != nil {
+		return err
+	}
+
+	note, err := h.repository.FindOne(r.Context(), noteNumber, optionsFromQuery(r))
+	if err != nil {
+		return err
+	}
+
+	noteBytes, err := json.Marshal(note)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(noteBytes)
+
+	return nil
+}
+
+func (h *handler) GetBuyerNotes(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("GET BUYER NOTES")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	buyerUUID := params.ByName("uuid")
+	if buyerUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+
+	filter, err := historyFilterFromQuery(r)
+	if err != nil {
+		return err
+	}
+
+	history, err := h.repository.FindByBuyer(r.Context(), buyerUUID, filter, optionsFromQuery(r))
+	if err != nil {
+		return err
+	}
+
+	historyBytes, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(historyBytes)
+
+	return nil
+}
+
+// historyFilterFromQuery reads the from, to, limit and offset query
+// parameters, e.g. /buyers/1/notes?from=2026-01-01&to=2026-03-31&limit=10.
+func historyFilterFromQuery(r *http.Request) (HistoryFilter, error) {
+	query := r.URL.Query()
+	filter := HistoryFilter{Limit: defaultHistoryLimit}
+
+	period, err := daterange.FromQuery(query)
+	if err != nil {
+		return HistoryFilter{}, apperror.BadRequestError(err.Error())
+	}
+	filter.Period = period
+
+	if limit := query.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit < 1 || filter.Limit > maxHistoryLimit {
+			return HistoryFilter{}, apperror.BadRequestError(fmt.Sprintf("limit must be an integer from 1 to %d", maxHistoryLimit))
+		}
+	}
+	if offset := query.Get("offset"); offset != "" {
+		filter.Offset, err = strconv.Atoi(offset)
+		if err != nil || filter.Offset < 0 {
+			return HistoryFilter{}, apperror.BadRequestError("offset must be a non-negative integer")
+		}
+	}
+
+	return filter, nil
+}
+
+func (h *handler) RestoreNote(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("RESTORE NOTE")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	noteNumber := params.ByName("uuid")
+	if noteNumber == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+
+	if err := h.repository.Restore(r.Context(), noteNumber); err != nil {
+		return err
+	}
+
+	note, err := h.repository.FindOne(r.Context(), noteNumber, optionsFromQuery(r))
+	if err != nil {
+		return err
+	}
+	noteBytes, err := json.Marshal(note)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(noteBytes)
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package note
+
+import (
+	"restapi-lesson/pkg/daterange"
+	"restapi-lesson/pkg/money"
+	"time"
+)
+
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+// HistoryFilter selects a page of the notes of a buyer dated within Period.
+type HistoryFilter struct {
+	Period daterange.Range
+	Limit  int
+	Offset int
+}
+
+// BuyerHistory is a page of the notes of a buyer, newest first, together with
+// statistics over the whole purchase history of the buyer.
+type BuyerHistory struct {
+	BuyerID int               `json:"buyer_id"`
+	Stats   BuyerStats        `json:"stats"`
+	Total   int               `json:"total"`
+	Limit   int               `json:"limit"`
+	Offset  int               `json:"offset"`
+	Notes   []NoteWithPrdList `json:"notes"`
+}
+
+// BuyerStats only counts confirmed and paid notes. TotalSpent is their gross
+// total less the goods returned with credit notes.
+type BuyerStats struct {
+	NotesCount        int                `json:"notes_count"`
+	TotalSpent        money.Money        `json:"total_spent"`
+	FirstPurchase     *time.Time         `json:"first_purchase"`
+	LastPurchase      *time.Time         `json:"last_purchase"`
+	FavouriteProducts []FavouriteProduct `json:"favourite_products"`
+}
+
+// FavouriteProduct is one of the products the buyer kept the most of.
+type FavouriteProduct struct {
+	ProductID int         `json:"product_id"`
+	Name      string      `json:"name"`
+	Amount    int         `json:"amount"`
+	Total     money.Money `json:"total"`
+}
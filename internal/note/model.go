@@ -1,23 +1,113 @@
 package note
 
-import "time"
+import (
+	"restapi-lesson/internal/buyer"
+	"restapi-lesson/internal/promotion"
+	"restapi-lesson/pkg/money"
+	"time"
+)
 
+// Note is the body of a new note. Goods are sold from the warehouse
+// WarehouseID, the main warehouse when it is left out; it can not be changed
+// afterwards. OverrideCreditLimit lets an administrator create the note even
+// though it takes the buyer over their credit limit.
 type Note struct {
-	Number  int       `json:"number"`
-	Date    time.Time `json:"date"`
-	BuyerID int       `json:"buyer_id"`
+	Number              int       `json:"number"`
+	Date                time.Time `json:"date"`
+	BuyerID             int       `json:"buyer_id"`
+	WarehouseID         int       `json:"warehouse_id,omitempty"`
+	Items               []Item    `json:"items,omitempty"`
+	OverrideCreditLimit bool      `json:"override_credit_limit,omitempty"`
 }
 
+type Item struct {
+	ProductID int `json:"product_id"`
+	Amount    int `json:"amount"`
+}
+
+// NoteWithPrdList is a note as it is read back. Number is the internal key;
+// InvoiceNumber is given to the note when it is confirmed and printed on the
+// invoice. A deleted note has DeletedAt set.
 type NoteWithPrdList struct {
-	Number   int       `json:"number"`
-	Date     time.Time `json:"date"`
-	BuyerID  int       `json:"buyer_id"`
-	PrdLists []PrdList `json:"prd_lists"`
+	Number        int          `json:"number"`
+	InvoiceNumber *string      `json:"invoice_number"`
+	Date          time.Time    `json:"date"`
+	BuyerID       int          `json:"buyer_id"`
+	Status        Status       `json:"status"`
+	WarehouseID   int          `json:"warehouse_id"`
+	Buyer         *buyer.Buyer `json:"buyer,omitempty"`
+	PrdLists      []PrdList    `json:"prd_lists"`
+	Taxes         []TaxGroup   `json:"taxes"`
+	Summary       Summary      `json:"summary"`
+	Quote         *Quote       `json:"quote,omitempty"`
+	// CreditLimitOverride records that the note went over the buyer's
+	// credit limit on an administrator's say-so.
+	CreditLimitOverride bool       `json:"credit_limit_override"`
+	DeletedAt           *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Summary is computed by the database from the line items of a note and the
+// discounts stored with them. GrandTotal is the gross amount, tax included.
+// Returned is the negative total of credit notes; Outstanding is what is left
+// to pay after returns and payments.
+type Summary struct {
+	Subtotal      money.Money `json:"subtotal"`
+	TotalQuantity int         `json:"total_quantity"`
+	LineCount     int         `json:"line_count"`
+	Discount      money.Money `json:"discount"`
+	Net           money.Money `json:"net"`
+	Tax           money.Money `json:"tax"`
+	GrandTotal    money.Money `json:"grand_total"`
+	Returned      money.Money `json:"returned"`
+	Paid          money.Money `json:"paid"`
+	Outstanding   money.Money `json:"outstanding"`
+}
+
+// Quote is what a draft note comes to with the promotions running at its
+// date. Drafts have no stored discounts, so their Summary leaves them out;
+// the discounts of the quote are stored when the note is confirmed.
+type Quote struct {
+	Discount    money.Money `json:"discount"`
+	Net         money.Money `json:"net"`
+	Tax         money.Money `json:"tax"`
+	GrandTotal  money.Money `json:"grand_total"`
+	Outstanding money.Money `json:"outstanding"`
 }
 
 type PrdList struct {
-	Name       string  `json:"name"`
-	Price      float64 `json:"price"`
-	Amount     int     `json:"amount"`
-	TotalCount float64 `json:"total_count"`
+	ID           int                  `json:"id"`
+	ProductID    int                  `json:"product_id"`
+	Name         string               `json:"name"`
+	Price        money.Money          `json:"price"`
+	Amount       int                  `json:"amount"`
+	TotalCount   money.Money          `json:"total_count"`
+	Discounts    []promotion.Discount `json:"discounts"`
+	Discount     money.Money          `json:"discount"`
+	Total        money.Money          `json:"total"`
+	TaxRate      money.Money          `json:"tax_rate"`
+	TaxInclusive bool                 `json:"tax_inclusive"`
+	Net          money.Money          `json:"net"`
+	Tax          money.Money          `json:"tax"`
+	Gross        money.Money          `json:"gross"`
+}
+
+// TaxGroup adds up the lines of a note taxed at the same rate.
+type TaxGroup struct {
+	Rate  money.Money `json:"rate"`
+	Net   money.Money `json:"net"`
+	Tax   money.Money `json:"tax"`
+	Gross money.Money `json:"gross"`
+}
+
+// Filter narrows down the list of notes. InvoiceNumber matches any part of
+// the invoice number, ignoring case. Deleted notes are left out unless
+// IncludeDeleted is set.
+type Filter struct {
+	InvoiceNumber  string
+	IncludeDeleted bool
+}
+
+// Options control what is loaded together with a note.
+type Options struct {
+	WithBuyer bool
 }
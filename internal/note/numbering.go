@@ -0,0 +1,19 @@
+package note
+
+import "fmt"
+
+// Numbering is the look of invoice numbers: with prefix INV and six digits
+// the 123rd note confirmed in 2026 is INV-2026-000123. An empty prefix is
+// left out.
+type Numbering struct {
+	Prefix string
+	Digits int
+}
+
+func (n Numbering) Format(year, sequence int) string {
+	number := fmt.Sprintf("%d-%0*d", year, n.Digits, sequence)
+	if n.Prefix == "" {
+		return number
+	}
+	return n.Prefix + "-" + number
+}
@@ -0,0 +1,32 @@
+package note
+
+type Status string
+
+const (
+	StatusDraft     Status = "draft"
+	StatusConfirmed Status = "confirmed"
+	StatusPaid      Status = "paid"
+	StatusCancelled Status = "cancelled"
+)
+
+// transitions lists the statuses a note may move to from each status.
+// Paid and cancelled notes are final.
+var transitions = map[Status][]Status{
+	StatusDraft:     {StatusConfirmed, StatusCancelled},
+	StatusConfirmed: {StatusPaid, StatusCancelled},
+}
+
+func (s Status) CanTransitionTo(to Status) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Editable reports whether line items of a note in this status may still be
+// added, changed or removed.
+func (s Status) Editable() bool {
+	return s == StatusDraft
+}
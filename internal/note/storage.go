@@ -6,8 +6,11 @@ import (
 
 type Repository interface {
 	Create(ctx context.Context, note *Note) error
-	FindAll(ctx context.Context) ([]NoteWithPrdList, error)
-	FindOne(ctx context.Context, id string) (NoteWithPrdList, error)
+	FindAll(ctx context.Context, filter Filter, opts Options) ([]NoteWithPrdList, error)
+	FindOne(ctx context.Context, id string, opts Options) (NoteWithPrdList, error)
 	Update(ctx context.Context, note Note) error
 	Delete(ctx context.Context, id string) error
+	Restore(ctx context.Context, id string) error
+	Transition(ctx context.Context, id string, to Status, overrideCreditLimit bool) error
+	FindByBuyer(ctx context.Context, buyerID string, filter HistoryFilter, opts Options) (BuyerHistory, error)
 }
@@ -0,0 +1,222 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/audit"
+	"restapi-lesson/internal/logging"
+	"restapi-lesson/internal/loyalty"
+	"restapi-lesson/internal/note"
+	noteDB "restapi-lesson/internal/note/db"
+	"restapi-lesson/internal/payment"
+	"restapi-lesson/pkg/client/postgresql"
+	"restapi-lesson/pkg/money"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+type repository struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func formatQuery(q string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(q, "\t", ""), "\n", " ")
+}
+
+// Create records a payment against a confirmed or paid note. Partial payments
+// leave the note confirmed; once the outstanding balance is covered the note
+// is moved to paid. Overpayments are kept and show up as a negative balance.
+// Payments in loyalty points are debited from the buyer's points and can not
+// exceed what is still owed on the note; they can also be made on a draft,
+// against its quote, and count once it is confirmed.
+func (r *repository) Create(ctx context.Context, pmt *payment.Payment) error {
+	if pmt.PaidAt.IsZero() {
+		pmt.PaidAt = time.Now()
+	}
+
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	q := `
+		SELECT
+		    status, buyer_id
+		FROM
+		    public.note
+		WHERE number = $1
+		FOR UPDATE
+	`
+
+	var status note.Status
+	var buyerID *int
+	err = tx.QueryRow(ctx, q, pmt.NoteID).Scan(&status, &buyerID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return apperror.ErrNotFound
+	}
+	if err != nil {
+		return r.wrapError(err)
+	}
+	if status == note.StatusDraft && pmt.Method != payment.MethodPoints {
+		return apperror.ConflictError(fmt.Sprintf("note %d is a draft, it can only be paid with points before it is confirmed", pmt.NoteID))
+	}
+	if status != note.StatusDraft && status != note.StatusConfirmed && status != note.StatusPaid {
+		return apperror.ConflictError(fmt.Sprintf("note %d is %s, only confirmed notes can be paid", pmt.NoteID, status))
+	}
+
+	q = `
+		INSERT INTO public.payment 
+		    (note_id, amount, method, paid_at) 
+		VALUES 
+		       ($1, $2, $3, $4) 
+		RETURNING id
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+	if err = tx.QueryRow(ctx, q, pmt.NoteID, pmt.Amount, pmt.Method, pmt.PaidAt).Scan(&pmt.ID); err != nil {
+		return r.wrapError(err)
+	}
+
+	if pmt.Method == payment.MethodPoints {
+		if err = r.redeemPoints(ctx, tx, buyerID, *pmt); err != nil {
+			return r.wrapError(err)
+		}
+	}
+
+	if status == note.StatusConfirmed {
+		var outstanding money.Money
+		q = `SELECT outstanding FROM public.note_balance WHERE number = $1`
+		if err = tx.QueryRow(ctx, q, pmt.NoteID).Scan(&outstanding); err != nil {
+			return r.wrapError(err)
+		}
+
+		if outstanding.Cmp(money.Money{}) <= 0 {
+			before, err := audit.Snapshot(ctx, tx, audit.EntityNote, pmt.NoteID)
+			if err != nil {
+				return r.wrapError(err)
+			}
+
+			q = `UPDATE public.note SET status = $1 WHERE number = $2`
+			if _, err = tx.Exec(ctx, q, note.StatusPaid, pmt.NoteID); err != nil {
+				return r.wrapError(err)
+			}
+
+			if err = audit.Log(ctx, tx, audit.EntityNote, pmt.NoteID, audit.ActionUpdate, before); err != nil {
+				return r.wrapError(err)
+			}
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// redeemPoints debits a payment in loyalty points from the buyer of the note.
+// It runs after the payment row is in, so the outstanding amount it checks
+// against already includes the payment itself; for a draft that is its
+// quote, discounts included.
+func (r *repository) redeemPoints(ctx context.Context, client postgresql.Client, buyerID *int, pmt payment.Payment) error {
+	if buyerID == nil {
+		return apperror.BadRequestError(fmt.Sprintf("note %d has no buyer to pay with points", pmt.NoteID))
+	}
+
+	outstanding, err := noteDB.Outstanding(ctx, client, pmt.NoteID)
+	if err != nil {
+		return err
+	}
+	if outstanding.IsNegative() {
+		return apperror.ConflictError(fmt.Sprintf("note %d: %s in points is more than is owed", pmt.NoteID, pmt.Amount))
+	}
+
+	return loyalty.Redeem(ctx, client, *buyerID, pmt.NoteID, pmt.ID, pmt.Amount)
+}
+
+func (r *repository) FindByNote(ctx context.Context, noteID string) ([]payment.Payment, error) {
+	q := `
+		SELECT
+		    id, note_id, amount, method, paid_at
+		FROM
+		    public.payment
+		WHERE note_id = $1
+		ORDER BY paid_at, id
+	`
+
+	rows, err := r.client.Query(ctx, q, noteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := make([]payment.Payment, 0)
+
+	for rows.Next() {
+		var pm payment.Payment
+
+		err = rows.Scan(&pm.ID, &pm.NoteID, &pm.Amount, &pm.Method, &pm.PaidAt)
+		if err != nil {
+			return nil, err
+		}
+
+		payments = append(payments, pm)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return payments, nil
+}
+
+// BuyerBalance adds up confirmed and paid notes of the buyer, less returns,
+// and every payment the buyer made, including payments on notes that were
+// cancelled afterwards.
+func (r *repository) BuyerBalance(ctx context.Context, buyerID string) (payment.Balance, error) {
+	q := `
+		SELECT
+		    b.id,
+		    COALESCE(SUM(nb.gross) FILTER (WHERE nb.status IN ('confirmed', 'paid')), 0),
+		    -COALESCE(SUM(nb.returned) FILTER (WHERE nb.status IN ('confirmed', 'paid')), 0),
+		    COALESCE(SUM(nb.paid), 0),
+		    COALESCE(SUM(nb.gross - nb.returned) FILTER (WHERE nb.status IN ('confirmed', 'paid')), 0) - COALESCE(SUM(nb.paid), 0)
+		FROM
+		    public.buyer AS b
+		    LEFT JOIN public.note_balance AS nb ON nb.buyer_id = b.id
+		WHERE b.id = $1
+		GROUP BY b.id
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+
+	var balance payment.Balance
+	err := r.client.QueryRow(ctx, q, buyerID).
+		Scan(&balance.BuyerID, &balance.Invoiced, &balance.Returned, &balance.Paid, &balance.Outstanding)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return payment.Balance{}, apperror.ErrNotFound
+	}
+	if err != nil {
+		return payment.Balance{}, err
+	}
+
+	return balance, nil
+}
+
+func (r *repository) wrapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+	return err
+}
+
+func NewRepository(client postgresql.Client, logger *logging.Logger) payment.Repository {
+	return &repository{
+		client: client,
+		logger: logger,
+	}
+}
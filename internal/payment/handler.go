@@ -0,0 +1,132 @@
+package payment
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/handlers"
+	"restapi-lesson/internal/logging"
+	"strconv"
+)
+
+const (
+	notePaymentsURL = "/notes/:uuid/payments"
+	buyerBalanceURL = "/buyers/:uuid/balance"
+)
+
+type handler struct {
+	logger     *logging.Logger
+	repository Repository
+}
+
+func NewHandler(repository Repository, logger *logging.Logger) handlers.Handler {
+	return &handler{
+		repository: repository,
+		logger:     logger,
+	}
+}
+
+func (h *handler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, notePaymentsURL, apperror.Middleware(h.GetNotePayments))
+	router.HandlerFunc(http.MethodPost, notePaymentsURL, apperror.Middleware(h.CreatePayment))
+	router.HandlerFunc(http.MethodGet, buyerBalanceURL, apperror.Middleware(h.GetBuyerBalance))
+}
+
+func (h *handler) GetNotePayments(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("GET NOTE PAYMENTS")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	noteUUID := params.ByName("uuid")
+	if noteUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+
+	payments, err := h.repository.FindByNote(r.Context(), noteUUID)
+	if err != nil {
+		return err
+	}
+
+	paymentsBytes, err := json.Marshal(payments)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(paymentsBytes)
+
+	return nil
+}
+
+func (h *handler) CreatePayment(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("CREATE PAYMENT")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	noteUUID := params.ByName("uuid")
+	if noteUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+	noteID, err := strconv.Atoi(noteUUID)
+	if err != nil {
+		return err
+	}
+
+	var pm Payment
+
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&pm); err != nil {
+		return apperror.BadRequestError("invalid data")
+	}
+	pm.NoteID = noteID
+	if err = pm.Validate(); err != nil {
+		return err
+	}
+
+	err = h.repository.Create(r.Context(), &pm)
+	if err != nil {
+		return err
+	}
+
+	paymentBytes, err := json.Marshal(pm)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/notes/%v/payments", noteID))
+	w.WriteHeader(http.StatusCreated)
+	w.Write(paymentBytes)
+
+	return nil
+}
+
+func (h *handler) GetBuyerBalance(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("GET BUYER BALANCE")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	buyerUUID := params.ByName("uuid")
+	if buyerUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+
+	balance, err := h.repository.BuyerBalance(r.Context(), buyerUUID)
+	if err != nil {
+		return err
+	}
+
+	balanceBytes, err := json.Marshal(balance)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(balanceBytes)
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package payment
+
+import (
+	"fmt"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/pkg/money"
+	"time"
+)
+
+type Method string
+
+const (
+	MethodCash     Method = "cash"
+	MethodCard     Method = "card"
+	MethodTransfer Method = "transfer"
+	// MethodPoints pays with the buyer's loyalty points, one point per rouble.
+	MethodPoints Method = "points"
+)
+
+type Payment struct {
+	ID     int         `json:"id"`
+	NoteID int         `json:"note_id"`
+	Amount money.Money `json:"amount"`
+	Method Method      `json:"method"`
+	PaidAt time.Time   `json:"paid_at"`
+}
+
+func (p Payment) Validate() error {
+	if p.Amount.Cmp(money.Money{}) <= 0 {
+		return apperror.BadRequestError("amount must be positive")
+	}
+
+	switch p.Method {
+	case MethodCash, MethodCard, MethodTransfer, MethodPoints:
+	default:
+		return apperror.BadRequestError(fmt.Sprintf("unknown payment method %q", p.Method))
+	}
+
+	return nil
+}
+
+// Balance sums up what a buyer owes across all their notes. Outstanding is
+// positive while the buyer is in debt and negative when they overpaid.
+type Balance struct {
+	BuyerID     int         `json:"buyer_id"`
+	Invoiced    money.Money `json:"invoiced"`
+	Returned    money.Money `json:"returned"`
+	Paid        money.Money `json:"paid"`
+	Outstanding money.Money `json:"outstanding"`
+}
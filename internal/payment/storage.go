@@ -0,0 +1,11 @@
+package payment
+
+import (
+	"context"
+)
+
+type Repository interface {
+	Create(ctx context.Context, payment *Payment) error
+	FindByNote(ctx context.Context, noteID string) ([]Payment, error)
+	BuyerBalance(ctx context.Context, buyerID string) (Balance, error)
+}
@@ -4,17 +4,24 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/audit"
 	"restapi-lesson/internal/logging"
+	"restapi-lesson/internal/note"
 	"restapi-lesson/internal/prdlist"
+	"restapi-lesson/internal/stock"
 	"restapi-lesson/pkg/client/postgresql"
+	"strconv"
 	"strings"
 
 	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
 )
 
 type repository struct {
-	client postgresql.Client
-	logger *logging.Logger
+	client  postgresql.Client
+	logger  *logging.Logger
+	alerter *stock.Alerter
 }
 
 func formatQuery(q string) string {
@@ -22,37 +29,88 @@ func formatQuery(q string) string {
 }
 
 func (r *repository) Create(ctx context.Context, productList *prdlist.ProductList) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	low, err := Insert(ctx, tx, productList)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+	if low != nil {
+		r.alerter.LowStock(*low)
+	}
+
+	return nil
+}
+
+// Insert stores a product list row together with a snapshot of the product
+// name, price and tax rate, so later changes do not rewrite the invoice, and
+// reserves its stock. It is meant to be called inside a transaction, so other
+// repositories can add line items as part of a bigger unit of work. A non-nil
+// LowStock is to be reported once that unit of work has been committed.
+func Insert(ctx context.Context, client postgresql.Client, productList *prdlist.ProductList) (*stock.LowStock, error) {
+	warehouseID, err := lockEditableNote(ctx, client, productList.NoteID)
+	if err != nil {
+		return nil, err
+	}
+
 	q := `
 		INSERT INTO product_list 
-		    (note_id, product_id, amount) 
-		VALUES 
-		       ($1, $2, $3) 
-		RETURNING id
+		    (note_id, product_id, amount, name, price, tax_rate, tax_inclusive) 
+		SELECT 
+		       $1::int, p.id, $3::int, p.name, p.price, COALESCE(t.rate, 0), COALESCE(t.inclusive, false) 
+		FROM public.product AS p 
+		    LEFT JOIN public.tax_category AS t ON t.id = p.tax_category_id 
+		WHERE p.id = $2 AND p.deleted_at IS NULL 
+		RETURNING id, name, price, tax_rate, tax_inclusive
 	`
-	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
-	if err := r.client.QueryRow(ctx, q, productList.NoteID, productList.ProductID, productList.Amount).Scan(&productList.ID); err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			pgErr = err.(*pgconn.PgError)
-			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
-			r.logger.Err.Println(newErr)
-			return newErr
-		}
-		return err
+	err = client.QueryRow(ctx, q, productList.NoteID, productList.ProductID, productList.Amount).
+		Scan(&productList.ID, &productList.Name, &productList.Price, &productList.TaxRate, &productList.TaxInclusive)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apperror.BadRequestError(fmt.Sprintf("product %d does not exist", productList.ProductID))
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	ref := stock.Ref{
+		Kind:          stock.KindSale,
+		Reason:        "line item added",
+		WarehouseID:   warehouseID,
+		NoteID:        productList.NoteID,
+		ProductListID: productList.ID,
+	}
+	low, err := stock.Reserve(ctx, client, productList.ProductID, productList.Amount, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = audit.Log(ctx, client, audit.EntityProductList, productList.ID, audit.ActionCreate, nil); err != nil {
+		return nil, err
+	}
+
+	return low, nil
 }
 
-func (r *repository) FindAll(ctx context.Context) ([]prdlist.ProductList, error) {
+// FindAll lists the product list rows, leaving out deleted ones unless
+// includeDeleted is set.
+func (r *repository) FindAll(ctx context.Context, includeDeleted bool) ([]prdlist.ProductList, error) {
 	q := `
 		SELECT
-		    id, note_id, product_id, amount
+		    id, note_id, product_id, amount, name, price, tax_rate, tax_inclusive, deleted_at
 		FROM
 		    public.product_list
+		WHERE $1 OR deleted_at IS NULL
 	`
 
-	rows, err := r.client.Query(ctx, q)
+	rows, err := r.client.Query(ctx, q, includeDeleted)
 	if err != nil {
 		return nil, err
 	}
@@ -62,7 +120,7 @@ func (r *repository) FindAll(ctx context.Context) ([]prdlist.ProductList, error)
 	for rows.Next() {
 		var pl prdlist.ProductList
 
-		err = rows.Scan(&pl.ID, &pl.NoteID, &pl.ProductID, &pl.Amount)
+		err = rows.Scan(&pl.ID, &pl.NoteID, &pl.ProductID, &pl.Amount, &pl.Name, &pl.Price, &pl.TaxRate, &pl.TaxInclusive, &pl.DeletedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -80,7 +138,7 @@ func (r *repository) FindAll(ctx context.Context) ([]prdlist.ProductList, error)
 func (r *repository) FindOne(ctx context.Context, id string) (prdlist.ProductList, error) {
 	q := `
 		SELECT
-		    id, note_id, product_id, amount
+		    id, note_id, product_id, amount, name, price, tax_rate, tax_inclusive, deleted_at
 		FROM
 		    public.product_list
 		WHERE id = $1
@@ -89,7 +147,7 @@ func (r *repository) FindOne(ctx context.Context, id string) (prdlist.ProductLis
 	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
 
 	var pl prdlist.ProductList
-	err := r.client.QueryRow(ctx, q, id).Scan(&pl.ID, &pl.NoteID, &pl.ProductID, &pl.Amount)
+	err := r.client.QueryRow(ctx, q, id).Scan(&pl.ID, &pl.NoteID, &pl.ProductID, &pl.Amount, &pl.Name, &pl.Price, &pl.TaxRate, &pl.TaxInclusive, &pl.DeletedAt)
 	if err != nil {
 		return prdlist.ProductList{}, err
 	}
@@ -98,61 +156,281 @@ func (r *repository) FindOne(ctx context.Context, id string) (prdlist.ProductLis
 }
 
 func (r *repository) Update(ctx context.Context, productList prdlist.ProductList) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	old, err := lockOne(ctx, tx, productList.ID)
+	if err != nil {
+		return r.wrapError(err)
+	}
+	before, err := audit.Snapshot(ctx, tx, audit.EntityProductList, old.ID)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	oldWarehouseID, err := lockEditableNote(ctx, tx, old.NoteID)
+	if err != nil {
+		return r.wrapError(err)
+	}
+	warehouseID := oldWarehouseID
+	if productList.NoteID != old.NoteID {
+		if warehouseID, err = lockEditableNote(ctx, tx, productList.NoteID); err != nil {
+			return r.wrapError(err)
+		}
+	}
+
+	if err = stock.Lock(ctx, tx, old.ProductID, productList.ProductID); err != nil {
+		return r.wrapError(err)
+	}
+	ref := stock.Ref{
+		Kind:          stock.KindSale,
+		Reason:        "line item changed",
+		WarehouseID:   oldWarehouseID,
+		NoteID:        old.NoteID,
+		ProductListID: old.ID,
+	}
+	if err = stock.Release(ctx, tx, old.ProductID, old.Amount, ref); err != nil {
+		return r.wrapError(err)
+	}
+	ref.WarehouseID = warehouseID
+	ref.NoteID = productList.NoteID
+	low, err := stock.Reserve(ctx, tx, productList.ProductID, productList.Amount, ref)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	// The snapshot is kept unless the row is switched to another product.
 	q := `
 		UPDATE 
-    		public.product_list
+    		public.product_list AS pl
 		SET
-			note_id = $1, product_id = $2, amount = $3
+			note_id = $1, product_id = $2, amount = $3,
+			name = CASE WHEN pl.product_id = $2 THEN pl.name ELSE p.name END,
+			price = CASE WHEN pl.product_id = $2 THEN pl.price ELSE p.price END,
+			tax_rate = CASE WHEN pl.product_id = $2 THEN pl.tax_rate ELSE COALESCE(t.rate, 0) END,
+			tax_inclusive = CASE WHEN pl.product_id = $2 THEN pl.tax_inclusive ELSE COALESCE(t.inclusive, false) END
+		FROM
+		    public.product AS p
+		    LEFT JOIN public.tax_category AS t ON t.id = p.tax_category_id
 		WHERE
-		    id = $4
+		    pl.id = $4 AND p.id = $2 AND (pl.product_id = $2 OR p.deleted_at IS NULL)
 	`
 
-	commandTag, err := r.client.Exec(ctx, q, productList.NoteID, productList.ProductID, productList.Amount, productList.ID)
+	commandTag, err := tx.Exec(ctx, q, productList.NoteID, productList.ProductID, productList.Amount, productList.ID)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			pgErr = err.(*pgconn.PgError)
-			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
-			r.logger.Err.Println(newErr)
-			return newErr
-		}
-		return err
+		return r.wrapError(err)
 	}
 	if commandTag.RowsAffected() != 1 {
-		newErr := errors.New("no row found to update")
-		r.logger.Err.Println(newErr)
-		return newErr
+		return apperror.BadRequestError(fmt.Sprintf("product %d does not exist", productList.ProductID))
+	}
+
+	if err = audit.Log(ctx, tx, audit.EntityProductList, old.ID, audit.ActionUpdate, before); err != nil {
+		return r.wrapError(err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+	if low != nil {
+		r.alerter.LowStock(*low)
 	}
 
 	return nil
 }
 
 func (r *repository) Delete(ctx context.Context, id string) error {
-	q := `DELETE FROM public.product_list WHERE id = $1`
-	commandTag, err := r.client.Exec(ctx, q, id)
-	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			pgErr = err.(*pgconn.PgError)
-			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
-			r.logger.Err.Println(newErr)
-			return newErr
-		}
+	plID, err := strconv.Atoi(id)
+	if err != nil {
+		return apperror.BadRequestError("id must be an integer")
+	}
+
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
 		return err
 	}
+	defer tx.Rollback(ctx)
 
-	if commandTag.RowsAffected() != 1 {
-		newErr := errors.New("no row found to delete")
-		r.logger.Err.Println(newErr)
-		return newErr
+	old, err := lockOne(ctx, tx, plID)
+	if err != nil {
+		return r.wrapError(err)
+	}
+	before, err := audit.Snapshot(ctx, tx, audit.EntityProductList, plID)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	warehouseID, err := lockEditableNote(ctx, tx, old.NoteID)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	ref := stock.Ref{
+		Kind:          stock.KindSale,
+		Reason:        "line item removed",
+		WarehouseID:   warehouseID,
+		NoteID:        old.NoteID,
+		ProductListID: old.ID,
+	}
+	if err = stock.Release(ctx, tx, old.ProductID, old.Amount, ref); err != nil {
+		return r.wrapError(err)
+	}
+
+	q := `UPDATE public.product_list SET deleted_at = now() WHERE id = $1`
+	if _, err = tx.Exec(ctx, q, plID); err != nil {
+		return r.wrapError(err)
+	}
+
+	if err = audit.Log(ctx, tx, audit.EntityProductList, plID, audit.ActionDelete, before); err != nil {
+		return r.wrapError(err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Restore brings a deleted row back onto its note, which must still be
+// editable, and takes its stock again.
+func (r *repository) Restore(ctx context.Context, id string) error {
+	plID, err := strconv.Atoi(id)
+	if err != nil {
+		return apperror.BadRequestError("id must be an integer")
+	}
+
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	q := `
+		SELECT
+		    id, note_id, product_id, amount
+		FROM
+		    public.product_list
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		FOR UPDATE
+	`
+
+	var old prdlist.ProductList
+	err = tx.QueryRow(ctx, q, plID).Scan(&old.ID, &old.NoteID, &old.ProductID, &old.Amount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return apperror.ErrNotFound
+	}
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	warehouseID, err := lockEditableNote(ctx, tx, old.NoteID)
+	if err != nil {
+		return r.wrapError(err)
+	}
+	before, err := audit.Snapshot(ctx, tx, audit.EntityProductList, plID)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	ref := stock.Ref{
+		Kind:          stock.KindSale,
+		Reason:        "line item restored",
+		WarehouseID:   warehouseID,
+		NoteID:        old.NoteID,
+		ProductListID: old.ID,
+	}
+	low, err := stock.Reserve(ctx, tx, old.ProductID, old.Amount, ref)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	q = `UPDATE public.product_list SET deleted_at = NULL WHERE id = $1`
+	if _, err = tx.Exec(ctx, q, plID); err != nil {
+		return r.wrapError(err)
+	}
+
+	if err = audit.Log(ctx, tx, audit.EntityProductList, plID, audit.ActionRestore, before); err != nil {
+		return r.wrapError(err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+	if low != nil {
+		r.alerter.LowStock(*low)
 	}
 
 	return nil
 }
 
-func NewRepository(client postgresql.Client, logger *logging.Logger) prdlist.Repository {
+// lockOne reads a product list row that is not deleted and locks it until the
+// end of the transaction, so that the amount being returned to stock can not
+// change underneath us.
+func lockOne(ctx context.Context, client postgresql.Client, id int) (prdlist.ProductList, error) {
+	q := `
+		SELECT
+		    id, note_id, product_id, amount
+		FROM
+		    public.product_list
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`
+
+	var pl prdlist.ProductList
+	err := client.QueryRow(ctx, q, id).Scan(&pl.ID, &pl.NoteID, &pl.ProductID, &pl.Amount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return prdlist.ProductList{}, apperror.ErrNotFound
+	}
+	if err != nil {
+		return prdlist.ProductList{}, err
+	}
+
+	return pl, nil
+}
+
+// lockEditableNote makes sure line items of the note may still be changed and
+// keeps the note from being confirmed or cancelled until the transaction ends.
+// It returns the warehouse the note sells from.
+func lockEditableNote(ctx context.Context, client postgresql.Client, noteID int) (int, error) {
+	q := `
+		SELECT
+		    status, warehouse_id
+		FROM
+		    public.note
+		WHERE number = $1 AND deleted_at IS NULL
+		FOR SHARE
+	`
+
+	var status note.Status
+	var warehouseID int
+	err := client.QueryRow(ctx, q, noteID).Scan(&status, &warehouseID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, apperror.BadRequestError(fmt.Sprintf("note %d does not exist", noteID))
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if !status.Editable() {
+		return 0, apperror.ConflictError(fmt.Sprintf("note %d is %s, its line items can not be changed", noteID, status))
+	}
+
+	return warehouseID, nil
+}
+
+func (r *repository) wrapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+	return err
+}
+
+func NewRepository(client postgresql.Client, logger *logging.Logger, alerter *stock.Alerter) prdlist.Repository {
 	return &repository{
-		client: client,
-		logger: logger,
+		client:  client,
+		logger:  logger,
+		alerter: alerter,
 	}
 }
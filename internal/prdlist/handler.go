@@ -12,8 +12,9 @@ import (
 )
 
 const (
-	prdListsURL = "/prdlists"
-	prdListURL  = "/prdlists/:uuid"
+	prdListsURL       = "/prdlists"
+	prdListURL        = "/prdlists/:uuid"
+	prdListRestoreURL = "/prdlists/:uuid/restore"
 )
 
 type handler struct {
@@ -34,6 +35,7 @@ func (h *handler) Register(router *httprouter.Router) {
 	router.HandlerFunc(http.MethodPost, prdListsURL, apperror.Middleware(h.CreateProductList))
 	router.HandlerFunc(http.MethodPatch, prdListURL, apperror.Middleware(h.UpdateProductList))
 	router.HandlerFunc(http.MethodDelete, prdListURL, apperror.Middleware(h.DeleteProductList))
+	router.HandlerFunc(http.MethodPost, prdListRestoreURL, apperror.Middleware(h.RestoreProductList))
 }
 
 func (h *handler) GetProductList(w http.ResponseWriter, r *http.Request) error {
@@ -68,7 +70,8 @@ func (h *handler) GetAllProductLists(w http.ResponseWriter, r *http.Request) err
 
 	h.logger.Info.Println("get category_uuid from URL")
 
-	productLists, err := h.repository.FindAll(r.Context())
+	includeDeleted := r.URL.Query().Get("include_deleted") == "true"
+	productLists, err := h.repository.FindAll(r.Context(), includeDeleted)
 	if err != nil {
 		return err
 	}
@@ -158,3 +161,33 @@ func (h *handler) DeleteProductList(w http.ResponseWriter, r *http.Request) erro
 
 	return nil
 }
+
+func (h *handler) RestoreProductList(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("RESTORE PRODUCT LIST")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	productListUUID := params.ByName("uuid")
+	if productListUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+
+	if err := h.repository.Restore(r.Context(), productListUUID); err != nil {
+		return err
+	}
+
+	productList, err := h.repository.FindOne(r.Context(), productListUUID)
+	if err != nil {
+		return err
+	}
+	productListBytes, err := json.Marshal(productList)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(productListBytes)
+
+	return nil
+}
@@ -1,8 +1,20 @@
 package prdlist
 
+import (
+	"restapi-lesson/pkg/money"
+	"time"
+)
+
+// ProductList is a line item of a note. A deleted line item has DeletedAt set
+// and no longer counts towards its note until it is restored.
 type ProductList struct {
-	ID        int `json:"id"`
-	NoteID    int `json:"note_id"`
-	ProductID int `json:"product_id"`
-	Amount    int `json:"amount"`
+	ID           int         `json:"id"`
+	NoteID       int         `json:"note_id"`
+	ProductID    int         `json:"product_id"`
+	Amount       int         `json:"amount"`
+	Name         string      `json:"name"`
+	Price        money.Money `json:"price"`
+	TaxRate      money.Money `json:"tax_rate"`
+	TaxInclusive bool        `json:"tax_inclusive"`
+	DeletedAt    *time.Time  `json:"deleted_at,omitempty"`
 }
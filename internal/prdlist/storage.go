@@ -6,8 +6,9 @@ import (
 
 type Repository interface {
 	Create(ctx context.Context, productList *ProductList) error
-	FindAll(ctx context.Context) ([]ProductList, error)
+	FindAll(ctx context.Context, includeDeleted bool) ([]ProductList, error)
 	FindOne(ctx context.Context, id string) (ProductList, error)
 	Update(ctx context.Context, productList ProductList) error
 	Delete(ctx context.Context, id string) error
+	Restore(ctx context.Context, id string) error
 }
@@ -0,0 +1,285 @@
+package product
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/pkg/money"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// CSV columns of the catalogue. id is written on export and ignored on
+// import, where products are matched by name.
+const (
+	columnID               = "id"
+	columnName             = "name"
+	columnDescription      = "description"
+	columnPrice            = "price"
+	columnAmount           = "amount"
+	columnTaxCategoryID    = "tax_category_id"
+	columnCategoryID       = "category_id"
+	columnReorderThreshold = "reorder_threshold"
+)
+
+var csvColumns = []string{
+	columnID, columnName, columnDescription, columnPrice, columnAmount,
+	columnTaxCategoryID, columnCategoryID, columnReorderThreshold,
+}
+
+// clearable are the columns an empty cell clears. An empty price, amount or
+// threshold leaves the product as it is.
+var clearable = map[string]bool{
+	columnDescription:   true,
+	columnTaxCategoryID: true,
+	columnCategoryID:    true,
+}
+
+// maxNameLength matches the product name and description columns.
+const maxNameLength = 100
+
+// WriteCSV writes the products as CSV with a header row.
+func WriteCSV(w io.Writer, products []Product) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvColumns); err != nil {
+		return err
+	}
+
+	for _, p := range products {
+		record := []string{
+			strconv.Itoa(p.ID), p.Name, p.Description, p.Price.String(), strconv.Itoa(p.Amount),
+			optionalID(p.TaxCategoryID), optionalID(p.CategoryID), strconv.Itoa(p.ReorderThreshold),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func optionalID(id *int) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.Itoa(*id)
+}
+
+type ImportStatus string
+
+const (
+	ImportCreated  ImportStatus = "created"
+	ImportUpdated  ImportStatus = "updated"
+	ImportRejected ImportStatus = "rejected"
+)
+
+// ImportRow is a row of an imported file. Columns holds the columns the row
+// sets: an existing product keeps the values of the others. Err is set when
+// the row did not pass validation.
+type ImportRow struct {
+	Line    int
+	Product Product
+	Columns map[string]bool
+	Err     error
+}
+
+// ImportResult says what became of a row of the file; Line is the line of the
+// file the row starts on, the header being line 1.
+type ImportResult struct {
+	Line      int          `json:"line"`
+	Name      string       `json:"name"`
+	ProductID int          `json:"product_id,omitempty"`
+	Status    ImportStatus `json:"status"`
+	Error     string       `json:"error,omitempty"`
+}
+
+type ImportReport struct {
+	Created  int            `json:"created"`
+	Updated  int            `json:"updated"`
+	Rejected int            `json:"rejected"`
+	Rows     []ImportResult `json:"rows"`
+}
+
+func (r *ImportReport) Add(result ImportResult) {
+	switch result.Status {
+	case ImportCreated:
+		r.Created++
+	case ImportUpdated:
+		r.Updated++
+	case ImportRejected:
+		r.Rejected++
+	}
+	r.Rows = append(r.Rows, result)
+}
+
+// ReadCSV reads a catalogue file. The header names the columns, in any order;
+// only name is required and unknown columns are ignored. Files saved by
+// spreadsheets with ';' as separator and ',' as decimal point are accepted
+// too. A row that does not validate comes back with Err set, the file as a
+// whole is only rejected when it can not be read.
+func ReadCSV(r io.Reader) ([]ImportRow, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimPrefix(string(data), "\ufeff")
+
+	cr := csv.NewReader(strings.NewReader(text))
+	header, _, _ := strings.Cut(text, "\n")
+	if strings.Count(header, ";") > strings.Count(header, ",") {
+		cr.Comma = ';'
+	}
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	columns, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, apperror.BadRequestError("file is empty")
+	}
+	if err != nil {
+		return nil, apperror.BadRequestError(fmt.Sprintf("invalid CSV: %v", err))
+	}
+
+	index := make(map[string]int, len(columns))
+	for i, c := range columns {
+		index[strings.ToLower(strings.TrimSpace(c))] = i
+	}
+	if _, ok := index[columnName]; !ok {
+		return nil, apperror.BadRequestError("CSV header must have a name column")
+	}
+
+	rows := make([]ImportRow, 0)
+	seen := make(map[string]int)
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, apperror.BadRequestError(fmt.Sprintf("invalid CSV: %v", err))
+		}
+
+		line, _ := cr.FieldPos(0)
+		row := ImportRow{Line: line, Columns: make(map[string]bool)}
+		field := func(column string) (string, bool) {
+			i, ok := index[column]
+			if !ok || i >= len(record) {
+				return "", false
+			}
+			value := strings.TrimSpace(record[i])
+			if value != "" || clearable[column] {
+				row.Columns[column] = true
+			}
+			return value, true
+		}
+		row.Err = parseRow(&row.Product, field)
+		if row.Err == nil {
+			if first, ok := seen[row.Product.Name]; ok {
+				row.Err = fmt.Errorf("name is already on line %d", first)
+			} else {
+				seen[row.Product.Name] = line
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func parseRow(p *Product, field func(column string) (string, bool)) error {
+	p.Name, _ = field(columnName)
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	if utf8.RuneCountInString(p.Name) > maxNameLength {
+		return fmt.Errorf("name is longer than %d characters", maxNameLength)
+	}
+
+	if s, ok := field(columnDescription); ok {
+		if utf8.RuneCountInString(s) > maxNameLength {
+			return fmt.Errorf("description is longer than %d characters", maxNameLength)
+		}
+		p.Description = s
+	}
+
+	if s, ok := field(columnPrice); ok && s != "" {
+		price, err := money.Parse(strings.Replace(s, ",", ".", 1))
+		if err != nil || price.IsNegative() {
+			return fmt.Errorf("price %q must be a non-negative amount", s)
+		}
+		p.Price = price
+	}
+
+	var err error
+	if p.Amount, err = nonNegative(field, columnAmount); err != nil {
+		return err
+	}
+	if p.ReorderThreshold, err = nonNegative(field, columnReorderThreshold); err != nil {
+		return err
+	}
+	if p.TaxCategoryID, err = reference(field, columnTaxCategoryID); err != nil {
+		return err
+	}
+	if p.CategoryID, err = reference(field, columnCategoryID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func nonNegative(field func(column string) (string, bool), column string) (int, error) {
+	s, ok := field(column)
+	if !ok || s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s %q must be a non-negative integer", column, s)
+	}
+	return n, nil
+}
+
+// reference reads an optional id; an empty cell clears it.
+func reference(field func(column string) (string, bool), column string) (*int, error) {
+	s, ok := field(column)
+	if !ok || s == "" {
+		return nil, nil
+	}
+	id, err := strconv.Atoi(s)
+	if err != nil || id <= 0 {
+		return nil, fmt.Errorf("%s %q must be a positive integer", column, s)
+	}
+	return &id, nil
+}
+
+// ChangesAmount reports whether the row sets an amount other than the one the
+// existing product has.
+func (row ImportRow) ChangesAmount(p Product) bool {
+	return row.Columns[columnAmount] && row.Product.Amount != p.Amount
+}
+
+// Apply overlays the columns the file has onto an existing product. The
+// amount is left as it is; it only sets the initial stock of new products.
+func (row ImportRow) Apply(p Product) Product {
+	p.Name = row.Product.Name
+	if row.Columns[columnDescription] {
+		p.Description = row.Product.Description
+	}
+	if row.Columns[columnPrice] {
+		p.Price = row.Product.Price
+	}
+	if row.Columns[columnTaxCategoryID] {
+		p.TaxCategoryID = row.Product.TaxCategoryID
+	}
+	if row.Columns[columnCategoryID] {
+		p.CategoryID = row.Product.CategoryID
+	}
+	if row.Columns[columnReorderThreshold] {
+		p.ReorderThreshold = row.Product.ReorderThreshold
+	}
+	return p
+}
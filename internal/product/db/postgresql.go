@@ -4,14 +4,23 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/audit"
 	"restapi-lesson/internal/logging"
 	"restapi-lesson/internal/product"
+	"restapi-lesson/internal/stock"
 	"restapi-lesson/pkg/client/postgresql"
 	"strings"
 
 	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
 )
 
+// similarityThreshold is how close, by pg_trgm word similarity, a product name
+// has to be to a query that found nothing by full text. 0.3 lets one or two
+// typos through in a word.
+const similarityThreshold = 0.3
+
 type repository struct {
 	client postgresql.Client
 	logger *logging.Logger
@@ -21,38 +30,68 @@ func formatQuery(q string) string {
 	return strings.ReplaceAll(strings.ReplaceAll(q, "\t", ""), "\n", " ")
 }
 
+// Create stores a new product. Its initial amount is booked as a receipt into
+// the main warehouse, so the stock journal accounts for every unit from the
+// start.
 func (r *repository) Create(ctx context.Context, product *product.Product) error {
+	if product.Amount < 0 {
+		return apperror.BadRequestError("amount must not be negative")
+	}
+
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err = r.create(ctx, tx, product); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *repository) create(ctx context.Context, client postgresql.Client, product *product.Product) error {
 	q := `
 		INSERT INTO product 
-		    (name, description, price, amount) 
+		    (name, description, price, amount, tax_category_id, category_id, reorder_threshold) 
 		VALUES 
-		       ($1, $2, $3, $4) 
+		       ($1, $2, $3, 0, $4, $5, $6) 
 		RETURNING id
 	`
 	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
-	if err := r.client.QueryRow(ctx, q, product.Name, product.Description, product.Price, product.Amount).Scan(&product.ID); err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			pgErr = err.(*pgconn.PgError)
-			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
-			r.logger.Err.Println(newErr)
-			return newErr
+	err := client.QueryRow(ctx, q, product.Name, product.Description, product.Price, product.TaxCategoryID, product.CategoryID, product.ReorderThreshold).Scan(&product.ID)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	if product.Amount > 0 {
+		ref := stock.Ref{Kind: stock.KindReceipt, Reason: "initial stock"}
+		if err = stock.Release(ctx, client, product.ID, product.Amount, ref); err != nil {
+			return r.wrapError(err)
 		}
-		return err
+	}
+
+	if err = audit.Log(ctx, client, audit.EntityProduct, product.ID, audit.ActionCreate, nil); err != nil {
+		return r.wrapError(err)
 	}
 
 	return nil
 }
 
-func (r *repository) FindAll(ctx context.Context) ([]product.Product, error) {
+// FindAll lists the products, leaving out deleted ones unless includeDeleted
+// is set.
+func (r *repository) FindAll(ctx context.Context, includeDeleted bool) ([]product.Product, error) {
 	q := `
 		SELECT
-		    id, name, description, price, amount
+		    id, name, description, price, amount, tax_category_id, category_id, reorder_threshold, deleted_at
 		FROM
 		    public.product
+		WHERE $1 OR deleted_at IS NULL
+		ORDER BY id
 	`
 
-	rows, err := r.client.Query(ctx, q)
+	rows, err := r.client.Query(ctx, q, includeDeleted)
 	if err != nil {
 		return nil, err
 	}
@@ -62,7 +101,7 @@ func (r *repository) FindAll(ctx context.Context) ([]product.Product, error) {
 	for rows.Next() {
 		var prd product.Product
 
-		err = rows.Scan(&prd.ID, &prd.Name, &prd.Description, &prd.Price, &prd.Amount)
+		err = rows.Scan(&prd.ID, &prd.Name, &prd.Description, &prd.Price, &prd.Amount, &prd.TaxCategoryID, &prd.CategoryID, &prd.ReorderThreshold, &prd.DeletedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -77,10 +116,94 @@ func (r *repository) FindAll(ctx context.Context) ([]product.Product, error) {
 	return products, nil
 }
 
+// FindLowStock lists the products whose stock is below their reorder
+// threshold, the largest shortfall first.
+func (r *repository) FindLowStock(ctx context.Context) ([]product.Product, error) {
+	q := `
+		SELECT
+		    id, name, description, price, amount, tax_category_id, category_id, reorder_threshold
+		FROM
+		    public.product
+		WHERE amount < reorder_threshold AND deleted_at IS NULL
+		ORDER BY reorder_threshold - amount DESC, id
+	`
+
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := make([]product.Product, 0)
+
+	for rows.Next() {
+		var prd product.Product
+
+		err = rows.Scan(&prd.ID, &prd.Name, &prd.Description, &prd.Price, &prd.Amount, &prd.TaxCategoryID, &prd.CategoryID, &prd.ReorderThreshold)
+		if err != nil {
+			return nil, err
+		}
+
+		products = append(products, prd)
+	}
+
+	return products, rows.Err()
+}
+
+// Search finds products by name and description with the russian full-text
+// configuration, so that "колбасы" finds "Колбаса", and falls back to trigram
+// similarity of the name for misspelt queries. Full-text matches come first.
+func (r *repository) Search(ctx context.Context, query string) ([]product.SearchResult, error) {
+	q := `
+		WITH query AS (
+		    SELECT to_tsquery('russian', $1) AS tsq
+		)
+		SELECT
+		    p.id, p.name, p.description, p.price, p.amount, p.tax_category_id, p.category_id, p.reorder_threshold,
+		    ts_rank(p.search_vector, q.tsq) + word_similarity($2, p.name) AS rank,
+		    ts_headline('russian', p.name, q.tsq, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+		    ts_headline('russian', p.description, q.tsq, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')
+		FROM
+		    public.product AS p, query AS q
+		WHERE p.deleted_at IS NULL
+		    AND (p.search_vector @@ q.tsq OR word_similarity($2, p.name) >= $3)
+		ORDER BY p.search_vector @@ q.tsq DESC, rank DESC, p.id
+		LIMIT $4
+	`
+
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q, product.PrefixQuery(query), query, similarityThreshold, product.MaxSearchResults)
+	if err != nil {
+		return nil, r.wrapError(err)
+	}
+	defer rows.Close()
+
+	results := make([]product.SearchResult, 0)
+
+	for rows.Next() {
+		var res product.SearchResult
+
+		err = rows.Scan(
+			&res.ID, &res.Name, &res.Description, &res.Price, &res.Amount, &res.TaxCategoryID, &res.CategoryID, &res.ReorderThreshold,
+			&res.Rank, &res.Highlight.Name, &res.Highlight.Description,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, res)
+	}
+
+	return results, rows.Err()
+}
+
 func (r *repository) FindOne(ctx context.Context, id string) (product.Product, error) {
 	q := `
 		SELECT
-		    id, name, description, price, amount
+		    id, name, description, price, amount, tax_category_id, category_id, reorder_threshold, deleted_at
 		FROM
 		    public.product
 		WHERE id = $1
@@ -89,7 +212,7 @@ func (r *repository) FindOne(ctx context.Context, id string) (product.Product, e
 	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
 
 	var prd product.Product
-	err := r.client.QueryRow(ctx, q, id).Scan(&prd.ID, &prd.Name, &prd.Description, &prd.Price, &prd.Amount)
+	err := r.client.QueryRow(ctx, q, id).Scan(&prd.ID, &prd.Name, &prd.Description, &prd.Price, &prd.Amount, &prd.TaxCategoryID, &prd.CategoryID, &prd.ReorderThreshold, &prd.DeletedAt)
 	if err != nil {
 		return product.Product{}, err
 	}
@@ -97,26 +220,41 @@ func (r *repository) FindOne(ctx context.Context, id string) (product.Product, e
 	return prd, nil
 }
 
+// Update changes a product. Its amount is left alone: stock only changes
+// through the stock journal. A deleted product has to be restored first.
 func (r *repository) Update(ctx context.Context, product product.Product) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err = r.update(ctx, tx, product); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *repository) update(ctx context.Context, client postgresql.Client, product product.Product) error {
+	before, err := audit.Snapshot(ctx, client, audit.EntityProduct, product.ID)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
 	q := `
 		UPDATE 
     		public.product
 		SET
-			name = $1, description = $2, price = $3, amount = $4
+			name = $1, description = $2, price = $3, tax_category_id = $4,
+			category_id = $5, reorder_threshold = $6
 		WHERE
-		    id = $5
+		    id = $7 AND deleted_at IS NULL
 	`
 
-	commandTag, err := r.client.Exec(ctx, q, product.Name, product.Description, product.Price, product.Amount, product.ID)
+	commandTag, err := client.Exec(ctx, q, product.Name, product.Description, product.Price, product.TaxCategoryID, product.CategoryID, product.ReorderThreshold, product.ID)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			pgErr = err.(*pgconn.PgError)
-			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
-			r.logger.Err.Println(newErr)
-			return newErr
-		}
-		return err
+		return r.wrapError(err)
 	}
 	if commandTag.RowsAffected() != 1 {
 		newErr := errors.New("no row found to update")
@@ -124,30 +262,163 @@ func (r *repository) Update(ctx context.Context, product product.Product) error
 		return newErr
 	}
 
+	if err = audit.Log(ctx, client, audit.EntityProduct, product.ID, audit.ActionUpdate, before); err != nil {
+		return r.wrapError(err)
+	}
+
 	return nil
 }
 
-func (r *repository) Delete(ctx context.Context, id string) error {
-	q := `DELETE FROM product WHERE id = $1`
-	commandTag, err := r.client.Exec(ctx, q, id)
-	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			pgErr = err.(*pgconn.PgError)
-			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
-			r.logger.Err.Println(newErr)
-			return newErr
+// Import creates or updates, matched by name, the products of an imported
+// file in a single transaction. Every row runs under its own savepoint, so a
+// row the database refuses is reported as rejected and the others still go
+// in.
+func (r *repository) Import(ctx context.Context, rows []product.ImportRow) (product.ImportReport, error) {
+	report := product.ImportReport{Rows: make([]product.ImportResult, 0, len(rows))}
+
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, row := range rows {
+		result := product.ImportResult{Line: row.Line, Name: row.Product.Name}
+		if row.Err != nil {
+			result.Status, result.Error = product.ImportRejected, row.Err.Error()
+			report.Add(result)
+			continue
 		}
+
+		sp, err := tx.Begin(ctx)
+		if err != nil {
+			return report, err
+		}
+
+		result.ProductID, result.Status, err = r.importRow(ctx, sp, row)
+		if err != nil {
+			if rbErr := sp.Rollback(ctx); rbErr != nil {
+				return report, rbErr
+			}
+			result.Status, result.Error = product.ImportRejected, err.Error()
+		} else if err = sp.Commit(ctx); err != nil {
+			return report, err
+		}
+
+		report.Add(result)
+	}
+
+	return report, tx.Commit(ctx)
+}
+
+func (r *repository) importRow(ctx context.Context, client postgresql.Client, row product.ImportRow) (int, product.ImportStatus, error) {
+	q := `
+		SELECT
+		    id, name, description, price, amount, tax_category_id, category_id, reorder_threshold, deleted_at
+		FROM
+		    public.product
+		WHERE name = $1
+		FOR UPDATE
+	`
+
+	var prd product.Product
+	err := client.QueryRow(ctx, q, row.Product.Name).Scan(&prd.ID, &prd.Name, &prd.Description, &prd.Price, &prd.Amount, &prd.TaxCategoryID, &prd.CategoryID, &prd.ReorderThreshold, &prd.DeletedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		created := row.Product
+		if err = r.create(ctx, client, &created); err != nil {
+			return 0, "", err
+		}
+		return created.ID, product.ImportCreated, nil
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	if prd.DeletedAt != nil {
+		return 0, "", fmt.Errorf("product %d is deleted, restore it first", prd.ID)
+	}
+	// The amount of an exported file comes back as it was; any other is
+	// refused, as stock is kept per warehouse and only changes through stock
+	// movements.
+	if row.ChangesAmount(prd) {
+		return 0, "", fmt.Errorf("amount of product %d can not be changed by import, post a stock movement instead", prd.ID)
+	}
+
+	if err = r.update(ctx, client, row.Apply(prd)); err != nil {
+		return 0, "", err
+	}
+	return prd.ID, product.ImportUpdated, nil
+}
+
+// Delete marks the product as deleted. It stays on the notes it was sold on
+// and can be restored.
+func (r *repository) Delete(ctx context.Context, id string) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
 		return err
 	}
+	defer tx.Rollback(ctx)
 
-	if commandTag.RowsAffected() != 1 {
+	before, err := audit.Snapshot(ctx, tx, audit.EntityProduct, id)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	q := `UPDATE public.product SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL RETURNING id`
+	var productID int
+	err = tx.QueryRow(ctx, q, id).Scan(&productID)
+	if errors.Is(err, pgx.ErrNoRows) {
 		newErr := errors.New("no row found to delete")
 		r.logger.Err.Println(newErr)
 		return newErr
 	}
+	if err != nil {
+		return r.wrapError(err)
+	}
 
-	return nil
+	if err = audit.Log(ctx, tx, audit.EntityProduct, productID, audit.ActionDelete, before); err != nil {
+		return r.wrapError(err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *repository) Restore(ctx context.Context, id string) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := audit.Snapshot(ctx, tx, audit.EntityProduct, id)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	q := `UPDATE public.product SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL RETURNING id`
+	var productID int
+	err = tx.QueryRow(ctx, q, id).Scan(&productID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return apperror.ErrNotFound
+	}
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	if err = audit.Log(ctx, tx, audit.EntityProduct, productID, audit.ActionRestore, before); err != nil {
+		return r.wrapError(err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *repository) wrapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+	return err
 }
 
 func NewRepository(client postgresql.Client, logger *logging.Logger) product.Repository {
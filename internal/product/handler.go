@@ -1,19 +1,34 @@
 package product
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/julienschmidt/httprouter"
+	"io"
 	"net/http"
 	"restapi-lesson/internal/apperror"
 	"restapi-lesson/internal/handlers"
 	"restapi-lesson/internal/logging"
 	"strconv"
+	"strings"
 )
 
 const (
-	productsURL = "/products"
-	productURL  = "/products/:uuid"
+	productsURL       = "/products"
+	productURL        = "/products/:uuid"
+	productRestoreURL = "/products/:uuid/restore"
+
+	// lowStockUUID, exportUUID and importUUID stand in for a product id:
+	// httprouter does not allow static routes such as /products/low-stock
+	// next to /products/:uuid.
+	lowStockUUID = "low-stock"
+	exportUUID   = "export.csv"
+	importUUID   = "import"
+
+	// maxImportSize limits the size of an imported CSV file.
+	maxImportSize = 10 << 20
 )
 
 type handler struct {
@@ -32,8 +47,10 @@ func (h *handler) Register(router *httprouter.Router) {
 	router.HandlerFunc(http.MethodGet, productURL, apperror.Middleware(h.GetProduct))
 	router.HandlerFunc(http.MethodGet, productsURL, apperror.Middleware(h.GetAllProducts))
 	router.HandlerFunc(http.MethodPost, productsURL, apperror.Middleware(h.CreateProduct))
+	router.HandlerFunc(http.MethodPost, productURL, apperror.Middleware(h.ImportProducts))
 	router.HandlerFunc(http.MethodPatch, productURL, apperror.Middleware(h.UpdateProduct))
 	router.HandlerFunc(http.MethodDelete, productURL, apperror.Middleware(h.DeleteProduct))
+	router.HandlerFunc(http.MethodPost, productRestoreURL, apperror.Middleware(h.RestoreProduct))
 }
 
 func (h *handler) GetProduct(w http.ResponseWriter, r *http.Request) error {
@@ -46,6 +63,12 @@ func (h *handler) GetProduct(w http.ResponseWriter, r *http.Request) error {
 	if productUUID == "" {
 		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
 	}
+	if productUUID == lowStockUUID {
+		return h.GetLowStockProducts(w, r)
+	}
+	if productUUID == exportUUID {
+		return h.ExportProducts(w, r)
+	}
 	h.logger.Info.Printf("get param: %v", productUUID)
 
 	product, err := h.repository.FindOne(r.Context(), productUUID)
@@ -69,7 +92,12 @@ func (h *handler) GetAllProducts(w http.ResponseWriter, r *http.Request) error {
 
 	h.logger.Info.Println("get category_uuid from URL")
 
-	products, err := h.repository.FindAll(r.Context())
+	if query := strings.TrimSpace(r.URL.Query().Get("q")); query != "" {
+		return h.SearchProducts(w, r, query)
+	}
+
+	includeDeleted := r.URL.Query().Get("include_deleted") == "true"
+	products, err := h.repository.FindAll(r.Context(), includeDeleted)
 	if err != nil {
 		return err
 	}
@@ -85,6 +113,120 @@ func (h *handler) GetAllProducts(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// SearchProducts answers /products?q=колбасы with the matching products, the
+// best match first.
+func (h *handler) SearchProducts(w http.ResponseWriter, r *http.Request, query string) error {
+	h.logger.Info.Printf("search products: %v", query)
+
+	results, err := h.repository.Search(r.Context(), query)
+	if err != nil {
+		return err
+	}
+
+	resultsBytes, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resultsBytes)
+
+	return nil
+}
+
+func (h *handler) GetLowStockProducts(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("GET LOW STOCK PRODUCTS")
+	w.Header().Set("Content-Type", "application/json")
+
+	products, err := h.repository.FindLowStock(r.Context())
+	if err != nil {
+		return err
+	}
+
+	productsBytes, err := json.Marshal(products)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(productsBytes)
+
+	return nil
+}
+
+// ExportProducts writes the whole catalogue as a CSV file that
+// ImportProducts takes back.
+func (h *handler) ExportProducts(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("EXPORT PRODUCTS")
+
+	products, err := h.repository.FindAll(r.Context(), false)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err = WriteCSV(&buf, products); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="products.csv"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+
+	return nil
+}
+
+// ImportProducts takes a CSV file, either as the request body or as the
+// "file" field of a multipart form, and creates or updates its products. The
+// response reports what became of every row.
+func (h *handler) ImportProducts(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("IMPORT PRODUCTS")
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	if params.ByName("uuid") != importUUID {
+		return apperror.ErrNotFound
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	defer r.Body.Close()
+
+	var file io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			return apperror.BadRequestError("multipart form must have a file field")
+		}
+		defer f.Close()
+		file = f
+	}
+
+	rows, err := ReadCSV(file)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return apperror.BadRequestError(fmt.Sprintf("file is larger than %d bytes", maxImportSize))
+		}
+		return err
+	}
+
+	report, err := h.repository.Import(r.Context(), rows)
+	if err != nil {
+		return err
+	}
+
+	reportBytes, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(reportBytes)
+
+	return nil
+}
+
 func (h *handler) CreateProduct(w http.ResponseWriter, r *http.Request) error {
 	h.logger.Info.Println("CREATE PRODUCT")
 	w.Header().Set("Content-Type", "application/json")
@@ -124,12 +266,21 @@ func (h *handler) UpdateProduct(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	var prd Product
+	// Amount is decoded on its own to tell whether the body has it: stock is
+	// kept per warehouse and only changes through stock movements.
+	var body struct {
+		Product
+		Amount *int `json:"amount"`
+	}
 	defer r.Body.Close()
 
-	if err := json.NewDecoder(r.Body).Decode(&prd); err != nil {
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		return apperror.BadRequestError("invalid data")
 	}
+	if body.Amount != nil {
+		return apperror.BadRequestError(fmt.Sprintf("amount can not be updated, post a stock movement to %s/%d/movements instead", productsURL, id))
+	}
+	prd := body.Product
 	prd.ID = id
 
 	err = h.repository.Update(r.Context(), prd)
@@ -161,3 +312,33 @@ func (h *handler) DeleteProduct(w http.ResponseWriter, r *http.Request) error {
 
 	return nil
 }
+
+func (h *handler) RestoreProduct(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("RESTORE PRODUCT")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	productUUID := params.ByName("uuid")
+	if productUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+
+	if err := h.repository.Restore(r.Context(), productUUID); err != nil {
+		return err
+	}
+
+	product, err := h.repository.FindOne(r.Context(), productUUID)
+	if err != nil {
+		return err
+	}
+	productBytes, err := json.Marshal(product)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(productBytes)
+
+	return nil
+}
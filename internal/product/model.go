@@ -1,9 +1,21 @@
 package product
 
+import (
+	"restapi-lesson/pkg/money"
+	"time"
+)
+
+// Product is low on stock once Amount drops below ReorderThreshold. A zero
+// threshold never triggers. A deleted product has DeletedAt set until it is
+// restored.
 type Product struct {
-	ID          int     `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Amount      int     `json:"amount"`
+	ID               int         `json:"id"`
+	Name             string      `json:"name"`
+	Description      string      `json:"description"`
+	Price            money.Money `json:"price"`
+	Amount           int         `json:"amount"`
+	TaxCategoryID    *int        `json:"tax_category_id,omitempty"`
+	CategoryID       *int        `json:"category_id,omitempty"`
+	ReorderThreshold int         `json:"reorder_threshold"`
+	DeletedAt        *time.Time  `json:"deleted_at,omitempty"`
 }
@@ -0,0 +1,39 @@
+package product
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MaxSearchResults caps the number of products a search returns.
+const MaxSearchResults = 50
+
+// SearchResult is a product found by GET /products?q=. Rank orders the
+// results, full-text matches first; Highlight holds the name and description
+// with the matched words wrapped in <mark> tags. Products found only through
+// the trigram fallback, typically a misspelt query, come without marks.
+type SearchResult struct {
+	Product
+	Rank      float64   `json:"rank"`
+	Highlight Highlight `json:"highlight"`
+}
+
+type Highlight struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// PrefixQuery turns what staff typed into a tsquery that matches every word as
+// a prefix, so that "колб" finds "Колбаса". Anything but letters and digits
+// separates words, which also keeps tsquery operators out of the query.
+func PrefixQuery(q string) string {
+	words := strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, 0, len(words))
+	for _, w := range words {
+		terms = append(terms, w+":*")
+	}
+	return strings.Join(terms, " & ")
+}
@@ -6,8 +6,12 @@ import (
 
 type Repository interface {
 	Create(ctx context.Context, product *Product) error
-	FindAll(ctx context.Context) ([]Product, error)
+	FindAll(ctx context.Context, includeDeleted bool) ([]Product, error)
 	FindOne(ctx context.Context, id string) (Product, error)
+	FindLowStock(ctx context.Context) ([]Product, error)
+	Search(ctx context.Context, query string) ([]SearchResult, error)
+	Import(ctx context.Context, rows []ImportRow) (ImportReport, error)
 	Update(ctx context.Context, product Product) error
 	Delete(ctx context.Context, id string) error
+	Restore(ctx context.Context, id string) error
 }
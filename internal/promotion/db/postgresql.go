@@ -0,0 +1,169 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/logging"
+	"restapi-lesson/internal/promotion"
+	"restapi-lesson/pkg/client/postgresql"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+type repository struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func formatQuery(q string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(q, "\t", ""), "\n", " ")
+}
+
+func (r *repository) Create(ctx context.Context, promotion *promotion.Promotion) error {
+	q := `
+		INSERT INTO public.promotion 
+		    (name, kind, product_id, percent, amount, buy_quantity, free_quantity, starts_at, ends_at) 
+		VALUES 
+		       ($1, $2, $3, $4, $5, $6, $7, $8, $9) 
+		RETURNING id
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+	err := r.client.QueryRow(ctx, q,
+		promotion.Name, promotion.Kind, promotion.ProductID, promotion.Percent, promotion.Amount,
+		promotion.Buy, promotion.Free, promotion.StartsAt, promotion.EndsAt,
+	).Scan(&promotion.ID)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	return nil
+}
+
+const promotionQuery = `
+		SELECT
+		    id, name, kind, product_id, percent, amount, buy_quantity, free_quantity, starts_at, ends_at
+		FROM
+		    public.promotion
+	`
+
+func scanPromotion(row pgx.Row) (promotion.Promotion, error) {
+	var p promotion.Promotion
+	err := row.Scan(&p.ID, &p.Name, &p.Kind, &p.ProductID, &p.Percent, &p.Amount, &p.Buy, &p.Free, &p.StartsAt, &p.EndsAt)
+	return p, err
+}
+
+// List returns every promotion. It takes a client rather than a repository so
+// the note repository can apply promotions inside its own transactions.
+func List(ctx context.Context, client postgresql.Client) ([]promotion.Promotion, error) {
+	q := promotionQuery + `
+		ORDER BY id
+	`
+
+	rows, err := client.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	promotions := make([]promotion.Promotion, 0)
+
+	for rows.Next() {
+		p, err := scanPromotion(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		promotions = append(promotions, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return promotions, nil
+}
+
+func (r *repository) FindAll(ctx context.Context) ([]promotion.Promotion, error) {
+	return List(ctx, r.client)
+}
+
+func (r *repository) FindOne(ctx context.Context, id string) (promotion.Promotion, error) {
+	q := promotionQuery + `
+		WHERE id = $1
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+
+	p, err := scanPromotion(r.client.QueryRow(ctx, q, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return promotion.Promotion{}, apperror.ErrNotFound
+	}
+	if err != nil {
+		return promotion.Promotion{}, err
+	}
+
+	return p, nil
+}
+
+func (r *repository) Update(ctx context.Context, promotion promotion.Promotion) error {
+	q := `
+		UPDATE 
+    		public.promotion
+		SET
+			name = $1, kind = $2, product_id = $3, percent = $4, amount = $5,
+			buy_quantity = $6, free_quantity = $7, starts_at = $8, ends_at = $9
+		WHERE
+		    id = $10
+	`
+
+	commandTag, err := r.client.Exec(ctx, q,
+		promotion.Name, promotion.Kind, promotion.ProductID, promotion.Percent, promotion.Amount,
+		promotion.Buy, promotion.Free, promotion.StartsAt, promotion.EndsAt, promotion.ID,
+	)
+	if err != nil {
+		return r.wrapError(err)
+	}
+	if commandTag.RowsAffected() != 1 {
+		newErr := errors.New("no row found to update")
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+
+	return nil
+}
+
+func (r *repository) Delete(ctx context.Context, id string) error {
+	q := `DELETE FROM public.promotion WHERE id = $1`
+	commandTag, err := r.client.Exec(ctx, q, id)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	if commandTag.RowsAffected() != 1 {
+		newErr := errors.New("no row found to delete")
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+
+	return nil
+}
+
+func (r *repository) wrapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+	return err
+}
+
+func NewRepository(client postgresql.Client, logger *logging.Logger) promotion.Repository {
+	return &repository{
+		client: client,
+		logger: logger,
+	}
+}
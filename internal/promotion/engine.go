@@ -0,0 +1,108 @@
+package promotion
+
+import (
+	"restapi-lesson/pkg/money"
+	"sort"
+	"time"
+)
+
+// Line is what the engine needs to know about a line item.
+type Line struct {
+	ProductID int
+	Price     money.Money
+	Amount    int
+}
+
+// Apply works out the discounts the promotions give on the line items of a
+// note dated at. The result holds the discounts of every line, in the order
+// of lines.
+//
+// Line promotions are applied first, in the order they were created, then
+// fixed note discounts are split over the lines in proportion to what is
+// left to pay on each of them. A line is never discounted below zero.
+func Apply(promotions []Promotion, lines []Line, at time.Time) [][]Discount {
+	result := make([][]Discount, len(lines))
+	remaining := make([]money.Money, len(lines))
+	for i, line := range lines {
+		remaining[i] = line.Price.Mul(line.Amount)
+	}
+
+	give := func(i int, p Promotion, amount money.Money) {
+		amount = money.Min(amount, remaining[i])
+		if amount.Cmp(money.Money{}) <= 0 {
+			return
+		}
+		remaining[i] = remaining[i].Sub(amount)
+		result[i] = append(result[i], Discount{PromotionID: p.ID, Name: p.Name, Amount: amount})
+	}
+
+	active := make([]Promotion, 0, len(promotions))
+	for _, p := range promotions {
+		if p.ActiveAt(at) {
+			active = append(active, p)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].ID < active[j].ID })
+
+	for _, p := range active {
+		for i, line := range lines {
+			if !p.appliesTo(line.ProductID) {
+				continue
+			}
+
+			switch p.Kind {
+			case KindProductPercent:
+				give(i, p, line.Price.Mul(line.Amount).Percent(p.Percent))
+			case KindBuyNGetM:
+				free := line.Amount / (p.Buy + p.Free) * p.Free
+				give(i, p, line.Price.Mul(free))
+			}
+		}
+	}
+
+	for _, p := range active {
+		if p.Kind != KindNoteFixed {
+			continue
+		}
+
+		total := money.Sum(remaining...)
+		if total.Cmp(money.Money{}) <= 0 {
+			continue
+		}
+		off := money.Min(p.Amount, total)
+
+		// Shares are rounded down. The cents rounding leaves over go to the
+		// lines with the most left to pay after their share; as off is at
+		// most the total, they always have room for them.
+		shares := make([]money.Money, len(lines))
+		left := off
+		order := make([]int, 0, len(lines))
+		for i := range lines {
+			if remaining[i].IsZero() {
+				continue
+			}
+
+			shares[i] = money.FromCents(off.Cents() * remaining[i].Cents() / total.Cents())
+			left = left.Sub(shares[i])
+			order = append(order, i)
+		}
+
+		sort.SliceStable(order, func(a, b int) bool {
+			return remaining[order[a]].Sub(shares[order[a]]).Cmp(remaining[order[b]].Sub(shares[order[b]])) > 0
+		})
+		for _, i := range order {
+			if left.IsZero() {
+				break
+			}
+			extra := money.Min(left, remaining[i].Sub(shares[i]))
+			shares[i] = shares[i].Add(extra)
+			left = left.Sub(extra)
+		}
+
+		for i := range lines {
+			give(i, p, shares[i])
+		}
+	}
+
+	return result
+}
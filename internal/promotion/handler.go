@@ -0,0 +1,167 @@
+package promotion
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/handlers"
+	"restapi-lesson/internal/logging"
+	"strconv"
+)
+
+const (
+	promotionsURL = "/promotions"
+	promotionURL  = "/promotions/:uuid"
+)
+
+type handler struct {
+	logger     *logging.Logger
+	repository Repository
+}
+
+func NewHandler(repository Repository, logger *logging.Logger) handlers.Handler {
+	return &handler{
+		repository: repository,
+		logger:     logger,
+	}
+}
+
+func (h *handler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, promotionURL, apperror.Middleware(h.GetPromotion))
+	router.HandlerFunc(http.MethodGet, promotionsURL, apperror.Middleware(h.GetAllPromotions))
+	router.HandlerFunc(http.MethodPost, promotionsURL, apperror.Middleware(h.CreatePromotion))
+	router.HandlerFunc(http.MethodPatch, promotionURL, apperror.Middleware(h.UpdatePromotion))
+	router.HandlerFunc(http.MethodDelete, promotionURL, apperror.Middleware(h.DeletePromotion))
+}
+
+func (h *handler) GetPromotion(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("GET PROMOTION")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	promotionUUID := params.ByName("uuid")
+	if promotionUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+	h.logger.Info.Printf("get param: %v", promotionUUID)
+
+	promotion, err := h.repository.FindOne(r.Context(), promotionUUID)
+	if err != nil {
+		return err
+	}
+	promotionBytes, err := json.Marshal(promotion)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(promotionBytes)
+
+	return nil
+}
+
+func (h *handler) GetAllPromotions(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("GET ALL PROMOTIONS")
+	w.Header().Set("Content-Type", "application/json")
+
+	promotions, err := h.repository.FindAll(r.Context())
+	if err != nil {
+		return err
+	}
+
+	promotionsBytes, err := json.Marshal(promotions)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(promotionsBytes)
+
+	return nil
+}
+
+func (h *handler) CreatePromotion(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("CREATE PROMOTION")
+	w.Header().Set("Content-Type", "application/json")
+
+	var prm Promotion
+
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&prm); err != nil {
+		return apperror.BadRequestError("invalid data")
+	}
+	if err := prm.Validate(); err != nil {
+		return err
+	}
+
+	err := h.repository.Create(r.Context(), &prm)
+	if err != nil {
+		return err
+	}
+
+	promotionUUID := prm.ID
+	w.Header().Set("Location", fmt.Sprintf("%s/%v", promotionsURL, promotionUUID))
+	w.WriteHeader(http.StatusCreated)
+
+	return nil
+}
+
+func (h *handler) UpdatePromotion(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("UPDATE PROMOTION")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	promotionUUID := params.ByName("uuid")
+	if promotionUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+
+	id, err := strconv.Atoi(promotionUUID)
+	if err != nil {
+		return err
+	}
+
+	var prm Promotion
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&prm); err != nil {
+		return apperror.BadRequestError("invalid data")
+	}
+	prm.ID = id
+	if err = prm.Validate(); err != nil {
+		return err
+	}
+
+	err = h.repository.Update(r.Context(), prm)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *handler) DeletePromotion(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("DELETE PROMOTION")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	promotionUUID := params.ByName("uuid")
+	if promotionUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+
+	err := h.repository.Delete(r.Context(), promotionUUID)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package promotion
+
+import (
+	"fmt"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/pkg/money"
+	"time"
+)
+
+type Kind string
+
+const (
+	// KindProductPercent takes Percent off the lines of ProductID, or off every
+	// line when no product is set.
+	KindProductPercent Kind = "product_percent"
+	// KindNoteFixed takes a fixed Amount off the whole note.
+	KindNoteFixed Kind = "note_fixed"
+	// KindBuyNGetM gives Free units of ProductID for every Buy units bought.
+	KindBuyNGetM Kind = "buy_n_get_m"
+)
+
+type Promotion struct {
+	ID        int         `json:"id"`
+	Name      string      `json:"name"`
+	Kind      Kind        `json:"kind"`
+	ProductID *int        `json:"product_id,omitempty"`
+	Percent   money.Money `json:"percent"`
+	Amount    money.Money `json:"amount"`
+	Buy       int         `json:"buy"`
+	Free      int         `json:"free"`
+	StartsAt  *time.Time  `json:"starts_at,omitempty"`
+	EndsAt    *time.Time  `json:"ends_at,omitempty"`
+}
+
+// Discount is a promotion applied to a single line item.
+type Discount struct {
+	PromotionID int         `json:"promotion_id"`
+	Name        string      `json:"name"`
+	Amount      money.Money `json:"amount"`
+}
+
+func (p Promotion) Validate() error {
+	if p.Name == "" {
+		return apperror.BadRequestError("promotion name is required")
+	}
+
+	switch p.Kind {
+	case KindProductPercent:
+		if p.Percent.Cmp(money.FromInt(0)) <= 0 || p.Percent.Cmp(money.FromInt(100)) > 0 {
+			return apperror.BadRequestError("percent must be greater than 0 and at most 100")
+		}
+	case KindNoteFixed:
+		if p.Amount.Cmp(money.FromInt(0)) <= 0 {
+			return apperror.BadRequestError("amount must be positive")
+		}
+	case KindBuyNGetM:
+		if p.ProductID == nil {
+			return apperror.BadRequestError("product_id is required for buy_n_get_m promotions")
+		}
+		if p.Buy <= 0 || p.Free <= 0 {
+			return apperror.BadRequestError("buy and free must be positive integers")
+		}
+	default:
+		return apperror.BadRequestError(fmt.Sprintf("unknown promotion kind %q", p.Kind))
+	}
+
+	if p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt) {
+		return apperror.BadRequestError("ends_at must be after starts_at")
+	}
+
+	return nil
+}
+
+// ActiveAt reports whether the campaign window of the promotion covers t.
+// The window includes its start and excludes its end.
+func (p Promotion) ActiveAt(t time.Time) bool {
+	if p.StartsAt != nil && t.Before(*p.StartsAt) {
+		return false
+	}
+	if p.EndsAt != nil && !t.Before(*p.EndsAt) {
+		return false
+	}
+	return true
+}
+
+func (p Promotion) appliesTo(productID int) bool {
+	return p.ProductID == nil || *p.ProductID == productID
+}
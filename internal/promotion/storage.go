@@ -0,0 +1,13 @@
+package promotion
+
+import (
+	"context"
+)
+
+type Repository interface {
+	Create(ctx context.Context, promotion *Promotion) error
+	FindAll(ctx context.Context) ([]Promotion, error)
+	FindOne(ctx context.Context, id string) (Promotion, error)
+	Update(ctx context.Context, promotion Promotion) error
+	Delete(ctx context.Context, id string) error
+}
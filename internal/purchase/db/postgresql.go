@@ -0,0 +1,322 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/logging"
+	"restapi-lesson/internal/purchase"
+	"restapi-lesson/internal/stock"
+	"restapi-lesson/pkg/client/postgresql"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+type repository struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func formatQuery(q string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(q, "\t", ""), "\n", " ")
+}
+
+func (r *repository) Create(ctx context.Context, order *purchase.Order) error {
+	if order.OrderedAt.IsZero() {
+		order.OrderedAt = time.Now()
+	}
+
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if order.WarehouseID, err = stock.Warehouse(ctx, tx, order.WarehouseID); err != nil {
+		return err
+	}
+
+	q := `
+		INSERT INTO public.purchase_order 
+		    (supplier_id, warehouse_id, ordered_at) 
+		VALUES 
+		       ($1, $2, $3) 
+		RETURNING id, status
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+	if err = tx.QueryRow(ctx, q, order.SupplierID, order.WarehouseID, order.OrderedAt).Scan(&order.ID, &order.Status); err != nil {
+		return r.wrapError(err)
+	}
+
+	q = `
+		INSERT INTO public.purchase_order_line 
+		    (purchase_order_id, product_id, quantity, cost) 
+		VALUES 
+		       ($1, $2, $3, $4) 
+		RETURNING id
+	`
+	for i := range order.Lines {
+		line := &order.Lines[i]
+		line.Received = 0
+
+		if err = tx.QueryRow(ctx, q, order.ID, line.ProductID, line.Quantity, line.Cost).Scan(&line.ID); err != nil {
+			return r.wrapError(err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *repository) FindAll(ctx context.Context) ([]purchase.Order, error) {
+	q := `
+		SELECT
+		    id, supplier_id, warehouse_id, ordered_at, status
+		FROM
+		    public.purchase_order
+		ORDER BY id
+	`
+
+	rows, err := r.client.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := make([]purchase.Order, 0)
+	for rows.Next() {
+		var o purchase.Order
+		if err = rows.Scan(&o.ID, &o.SupplierID, &o.WarehouseID, &o.OrderedAt, &o.Status); err != nil {
+			return nil, err
+		}
+		o.Lines = make([]purchase.Line, 0)
+		orders = append(orders, o)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err = r.attachLines(ctx, orders); err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+func (r *repository) FindOne(ctx context.Context, id string) (purchase.Order, error) {
+	q := `
+		SELECT
+		    id, supplier_id, warehouse_id, ordered_at, status
+		FROM
+		    public.purchase_order
+		WHERE id = $1
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+
+	var o purchase.Order
+	err := r.client.QueryRow(ctx, q, id).Scan(&o.ID, &o.SupplierID, &o.WarehouseID, &o.OrderedAt, &o.Status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return purchase.Order{}, apperror.ErrNotFound
+	}
+	if err != nil {
+		return purchase.Order{}, err
+	}
+	o.Lines = make([]purchase.Line, 0)
+
+	orders := []purchase.Order{o}
+	if err = r.attachLines(ctx, orders); err != nil {
+		return purchase.Order{}, err
+	}
+
+	return orders[0], nil
+}
+
+// attachLines loads the lines of all the given orders with one query.
+func (r *repository) attachLines(ctx context.Context, orders []purchase.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*purchase.Order, len(orders))
+	ids := make([]int, 0, len(orders))
+	for i := range orders {
+		byID[orders[i].ID] = &orders[i]
+		ids = append(ids, orders[i].ID)
+	}
+
+	q := `
+		SELECT
+		    id, purchase_order_id, product_id, quantity, received, cost
+		FROM
+		    public.purchase_order_line
+		WHERE purchase_order_id = ANY($1)
+		ORDER BY id
+	`
+
+	rows, err := r.client.Query(ctx, q, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line purchase.Line
+		var orderID int
+		if err = rows.Scan(&line.ID, &orderID, &line.ProductID, &line.Quantity, &line.Received, &line.Cost); err != nil {
+			return err
+		}
+		byID[orderID].Lines = append(byID[orderID].Lines, line)
+	}
+
+	return rows.Err()
+}
+
+// Receive books a delivery against an order: the received quantities of its
+// lines grow, the goods go into stock as receipts and the order status
+// follows, all in one transaction. No line may receive more than was ordered.
+func (r *repository) Receive(ctx context.Context, id string, delivery purchase.Delivery) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	q := `
+		SELECT
+		    id, warehouse_id, status
+		FROM
+		    public.purchase_order
+		WHERE id = $1
+		FOR UPDATE
+	`
+
+	var orderID, warehouseID int
+	var status purchase.Status
+	err = tx.QueryRow(ctx, q, id).Scan(&orderID, &warehouseID, &status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return apperror.ErrNotFound
+	}
+	if err != nil {
+		return r.wrapError(err)
+	}
+	if status == purchase.StatusReceived {
+		return apperror.ConflictError(fmt.Sprintf("purchase order %d is already received in full", orderID))
+	}
+
+	q = `
+		SELECT
+		    id, product_id, quantity, received
+		FROM
+		    public.purchase_order_line
+		WHERE purchase_order_id = $1
+		ORDER BY id
+		FOR UPDATE
+	`
+
+	rows, err := tx.Query(ctx, q, orderID)
+	if err != nil {
+		return r.wrapError(err)
+	}
+	defer rows.Close()
+
+	lines := make(map[int]*purchase.Line)
+	for rows.Next() {
+		var line purchase.Line
+		if err = rows.Scan(&line.ID, &line.ProductID, &line.Quantity, &line.Received); err != nil {
+			return err
+		}
+		lines[line.ID] = &line
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	delivered := make(map[int]int)
+	productIDs := make([]int, 0, len(delivery.Lines))
+	for _, dl := range delivery.Lines {
+		line, ok := lines[dl.LineID]
+		if !ok {
+			return apperror.BadRequestError(fmt.Sprintf("line %d does not belong to purchase order %d", dl.LineID, orderID))
+		}
+		delivered[dl.LineID] += dl.Quantity
+		if line.Received+delivered[dl.LineID] > line.Quantity {
+			return apperror.ConflictError(fmt.Sprintf(
+				"line %d: %d ordered, %d already received, %d more delivered",
+				line.ID, line.Quantity, line.Received, delivered[dl.LineID],
+			))
+		}
+		productIDs = append(productIDs, line.ProductID)
+	}
+
+	if err = stock.Lock(ctx, tx, productIDs...); err != nil {
+		return r.wrapError(err)
+	}
+
+	q = `
+		UPDATE 
+    		public.purchase_order_line
+		SET
+			received = received + $1
+		WHERE
+		    id = $2
+	`
+	ref := stock.Ref{
+		Kind:        stock.KindReceipt,
+		Reason:      fmt.Sprintf("purchase order %d", orderID),
+		WarehouseID: warehouseID,
+	}
+	for lineID, quantity := range delivered {
+		line := lines[lineID]
+		if _, err = tx.Exec(ctx, q, quantity, lineID); err != nil {
+			return r.wrapError(err)
+		}
+		if err = stock.Release(ctx, tx, line.ProductID, quantity, ref); err != nil {
+			return r.wrapError(err)
+		}
+		line.Received += quantity
+	}
+
+	status = purchase.StatusReceived
+	for _, line := range lines {
+		if line.Received < line.Quantity {
+			status = purchase.StatusPartiallyReceived
+			break
+		}
+	}
+
+	q = `
+		UPDATE 
+    		public.purchase_order
+		SET
+			status = $1
+		WHERE
+		    id = $2
+	`
+	if _, err = tx.Exec(ctx, q, status, orderID); err != nil {
+		return r.wrapError(err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *repository) wrapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+	return err
+}
+
+func NewRepository(client postgresql.Client, logger *logging.Logger) purchase.Repository {
+	return &repository{
+		client: client,
+		logger: logger,
+	}
+}
@@ -0,0 +1,156 @@
+package purchase
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/handlers"
+	"restapi-lesson/internal/logging"
+	"strconv"
+)
+
+const (
+	ordersURL       = "/purchaseorders"
+	orderURL        = "/purchaseorders/:uuid"
+	orderReceiveURL = "/purchaseorders/:uuid/receive"
+)
+
+type handler struct {
+	logger     *logging.Logger
+	repository Repository
+}
+
+func NewHandler(repository Repository, logger *logging.Logger) handlers.Handler {
+	return &handler{
+		repository: repository,
+		logger:     logger,
+	}
+}
+
+func (h *handler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, orderURL, apperror.Middleware(h.GetOrder))
+	router.HandlerFunc(http.MethodGet, ordersURL, apperror.Middleware(h.GetAllOrders))
+	router.HandlerFunc(http.MethodPost, ordersURL, apperror.Middleware(h.CreateOrder))
+	router.HandlerFunc(http.MethodPost, orderReceiveURL, apperror.Middleware(h.ReceiveOrder))
+}
+
+func (h *handler) GetOrder(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("GET PURCHASE ORDER")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	orderUUID := params.ByName("uuid")
+	if orderUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+
+	order, err := h.repository.FindOne(r.Context(), orderUUID)
+	if err != nil {
+		return err
+	}
+
+	orderBytes, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(orderBytes)
+
+	return nil
+}
+
+func (h *handler) GetAllOrders(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("GET ALL PURCHASE ORDERS")
+	w.Header().Set("Content-Type", "application/json")
+
+	orders, err := h.repository.FindAll(r.Context())
+	if err != nil {
+		return err
+	}
+
+	ordersBytes, err := json.Marshal(orders)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(ordersBytes)
+
+	return nil
+}
+
+func (h *handler) CreateOrder(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("CREATE PURCHASE ORDER")
+	w.Header().Set("Content-Type", "application/json")
+
+	var order Order
+
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		return apperror.BadRequestError("invalid data")
+	}
+	if err := order.Validate(); err != nil {
+		return err
+	}
+
+	err := h.repository.Create(r.Context(), &order)
+	if err != nil {
+		return err
+	}
+
+	return h.writeOrder(w, r, order.ID, http.StatusCreated)
+}
+
+// ReceiveOrder books a delivery against the order, e.g.
+// {"lines": [{"line_id": 1, "quantity": 20}]}, and puts the goods into stock.
+func (h *handler) ReceiveOrder(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("RECEIVE PURCHASE ORDER")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	orderID, err := strconv.Atoi(params.ByName("uuid"))
+	if err != nil {
+		return apperror.BadRequestError("uuid query parameter is required and must be an integer")
+	}
+
+	var delivery Delivery
+
+	defer r.Body.Close()
+	if err = json.NewDecoder(r.Body).Decode(&delivery); err != nil {
+		return apperror.BadRequestError("invalid data")
+	}
+	if err = delivery.Validate(); err != nil {
+		return err
+	}
+
+	if err = h.repository.Receive(r.Context(), strconv.Itoa(orderID), delivery); err != nil {
+		return err
+	}
+
+	return h.writeOrder(w, r, orderID, http.StatusOK)
+}
+
+func (h *handler) writeOrder(w http.ResponseWriter, r *http.Request, id int, status int) error {
+	order, err := h.repository.FindOne(r.Context(), strconv.Itoa(id))
+	if err != nil {
+		return err
+	}
+
+	orderBytes, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+
+	if status == http.StatusCreated {
+		w.Header().Set("Location", fmt.Sprintf("%s/%v", ordersURL, id))
+	}
+	w.WriteHeader(status)
+	w.Write(orderBytes)
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package purchase
+
+import (
+	"fmt"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/pkg/money"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusOrdered           Status = "ordered"
+	StatusPartiallyReceived Status = "partially_received"
+	StatusReceived          Status = "received"
+)
+
+// Order is a purchase order placed with a supplier. Goods are delivered to
+// the warehouse WarehouseID, the main warehouse when it is left out. The
+// status follows the deliveries: ordered until something arrives, partially
+// received until every line is delivered in full, then received.
+type Order struct {
+	ID          int       `json:"id"`
+	SupplierID  int       `json:"supplier_id"`
+	WarehouseID int       `json:"warehouse_id"`
+	OrderedAt   time.Time `json:"ordered_at"`
+	Status      Status    `json:"status"`
+	Lines       []Line    `json:"lines"`
+}
+
+// Line orders Quantity units of a product at the unit Cost agreed with the
+// supplier. Received counts the units delivered so far.
+type Line struct {
+	ID        int         `json:"id"`
+	ProductID int         `json:"product_id"`
+	Quantity  int         `json:"quantity"`
+	Received  int         `json:"received"`
+	Cost      money.Money `json:"cost"`
+}
+
+func (o Order) Validate() error {
+	if o.SupplierID == 0 {
+		return apperror.BadRequestError("supplier_id is required")
+	}
+	if len(o.Lines) == 0 {
+		return apperror.BadRequestError("purchase order must have at least one line")
+	}
+	for i, line := range o.Lines {
+		if line.Quantity <= 0 {
+			return apperror.BadRequestError(fmt.Sprintf("line %d: quantity must be a positive integer", i+1))
+		}
+		if line.Cost.IsNegative() {
+			return apperror.BadRequestError(fmt.Sprintf("line %d: cost must not be negative", i+1))
+		}
+	}
+	return nil
+}
+
+// Delivery lists the quantities that arrived for the lines of an order.
+type Delivery struct {
+	Lines []DeliveryLine `json:"lines"`
+}
+
+type DeliveryLine struct {
+	LineID   int `json:"line_id"`
+	Quantity int `json:"quantity"`
+}
+
+func (d Delivery) Validate() error {
+	if len(d.Lines) == 0 {
+		return apperror.BadRequestError("delivery must have at least one line")
+	}
+	for _, line := range d.Lines {
+		if line.Quantity <= 0 {
+			return apperror.BadRequestError(fmt.Sprintf("line %d: quantity must be a positive integer", line.LineID))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+package purchase
+
+import (
+	"context"
+)
+
+type Repository interface {
+	Create(ctx context.Context, order *Order) error
+	FindAll(ctx context.Context) ([]Order, error)
+	FindOne(ctx context.Context, id string) (Order, error)
+	Receive(ctx context.Context, id string, delivery Delivery) error
+}
@@ -0,0 +1,273 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"restapi-lesson/internal/logging"
+	"restapi-lesson/internal/report"
+	"restapi-lesson/pkg/client/postgresql"
+	"strings"
+
+	"github.com/jackc/pgconn"
+)
+
+type repository struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func formatQuery(q string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(q, "\t", ""), "\n", " ")
+}
+
+// salesQuery lists every sold line of a confirmed or paid note and every
+// returned line of its credit notes, the latter with negative quantities and
+// amounts, so that the reports only have to group and add them up. subtree
+// pairs every category with itself and each of its descendants.
+const salesQuery = `
+		WITH RECURSIVE subtree AS (
+		    SELECT id AS root_id, id FROM public.category
+		    UNION
+		    SELECT s.root_id, c.id
+		    FROM
+		        public.category AS c
+		        INNER JOIN subtree AS s ON c.parent_id = s.id
+		),
+		sales AS (
+		    SELECT
+		        n.date, n.buyer_id, plt.product_id, pl.name,
+		        plt.amount AS units, 0 AS returned_units,
+		        plt.net, plt.tax, plt.gross, 0 AS returned
+		    FROM
+		        public.product_list_total AS plt
+		        INNER JOIN public.product_list AS pl ON pl.id = plt.id
+		        INNER JOIN public.note AS n ON n.number = plt.note_id
+		    WHERE n.status IN ('confirmed', 'paid')
+		    UNION ALL
+		    SELECT
+		        cn.date, n.buyer_id, cl.product_id, cl.name,
+		        -cl.amount, -cl.amount,
+		        -(cl.gross - cl.tax), -cl.tax, -cl.gross, -cl.gross
+		    FROM
+		        public.credit_note_line AS cl
+		        INNER JOIN public.credit_note AS cn ON cn.id = cl.credit_note_id
+		        INNER JOIN public.note AS n ON n.number = cn.note_id
+		    WHERE n.status IN ('confirmed', 'paid')
+		)
+	`
+
+// Key columns of a report row, in the order they are selected: product id
+// and name, buyer id and name, category id and name, period.
+const (
+	columnProductID = iota
+	columnProductName
+	columnBuyerID
+	columnBuyerName
+	columnCategoryID
+	columnCategoryName
+	columnPeriod
+)
+
+// dimension is what a report groups by: the columns it sets, the tables they
+// need besides the sales and what it adds to GROUP BY. Ranked dimensions are
+// ordered by revenue, periods by time. top tells whether a row counts
+// towards the total of the report.
+type dimension struct {
+	columns map[int]string
+	joins   string
+	key     string
+	ranked  bool
+	top     string
+}
+
+var dimensions = map[report.Grouping]dimension{
+	report.GroupByProduct: {
+		columns: map[int]string{
+			columnProductID:   `s.product_id`,
+			columnProductName: `(ARRAY_AGG(s.name ORDER BY s.date DESC))[1]`,
+		},
+		key:    `s.product_id`,
+		ranked: true,
+	},
+	report.GroupByBuyer: {
+		columns: map[int]string{
+			columnBuyerID:   `s.buyer_id`,
+			columnBuyerName: `COALESCE(MAX(b.name || ' ' || b.surname), '')`,
+		},
+		joins:  `LEFT JOIN public.buyer AS b ON b.id = s.buyer_id`,
+		key:    `s.buyer_id`,
+		ranked: true,
+	},
+	// A category row adds up the sales of the products in the category and
+	// all its subcategories, by the category the product is in now. Rows of
+	// subcategories are part of their parent's, so only top level categories
+	// and the products without a category count towards the total.
+	report.GroupByCategory: {
+		columns: map[int]string{
+			columnCategoryID:   `t.root_id`,
+			columnCategoryName: `COALESCE(MAX(c.name), '')`,
+		},
+		joins: `LEFT JOIN public.product AS p ON p.id = s.product_id
+		    LEFT JOIN subtree AS t ON t.id = p.category_id
+		    LEFT JOIN public.category AS c ON c.id = t.root_id`,
+		key:    `t.root_id`,
+		ranked: true,
+		top:    `BOOL_AND(c.parent_id IS NULL)`,
+	},
+	report.GroupByDay:   periodDimension("day"),
+	report.GroupByWeek:  periodDimension("week"),
+	report.GroupByMonth: periodDimension("month"),
+}
+
+// periodDimension groups by the start of the day, week (Monday) or month.
+func periodDimension(unit string) dimension {
+	period := fmt.Sprintf(`date_trunc('%s', s.date)`, unit)
+	return dimension{
+		columns: map[int]string{columnPeriod: period},
+		key:     period,
+	}
+}
+
+// grouping holds the parts of the report query that depend on what it is
+// grouped by.
+type grouping struct {
+	columns string
+	joins   string
+	groupBy string
+	orderBy string
+	top     string
+}
+
+// newGrouping combines the given dimensions. Rows are ordered by period
+// first, then by revenue.
+func newGrouping(groupBy []report.Grouping) (grouping, error) {
+	selected := []string{`NULL::int`, `NULL::text`, `NULL::int`, `NULL::text`, `NULL::int`, `NULL::text`, `NULL::timestamp`}
+	var joined, grouped, periods, ranked, tops []string
+	for _, g := range groupBy {
+		d, ok := dimensions[g]
+		if !ok {
+			return grouping{}, g.Validate()
+		}
+
+		for column, expr := range d.columns {
+			selected[column] = expr
+		}
+		if d.joins != "" {
+			joined = append(joined, d.joins)
+		}
+		grouped = append(grouped, d.key)
+		if d.ranked {
+			ranked = append(ranked, d.key)
+		} else {
+			periods = append(periods, d.key)
+		}
+		if d.top != "" {
+			tops = append(tops, d.top)
+		}
+	}
+	if len(grouped) == 0 {
+		return grouping{}, report.Grouping("").Validate()
+	}
+
+	order := append(periods, `SUM(s.gross) DESC`)
+	order = append(order, ranked...)
+	if len(tops) == 0 {
+		tops = append(tops, `true`)
+	}
+
+	return grouping{
+		columns: strings.Join(selected, ", "),
+		joins:   strings.Join(joined, "\n\t\t    "),
+		groupBy: strings.Join(grouped, ", "),
+		orderBy: strings.Join(order, ", "),
+		top:     strings.Join(tops, " AND "),
+	}, nil
+}
+
+func (r *repository) Sales(ctx context.Context, filter report.Filter) (report.Sales, error) {
+	g, err := newGrouping(filter.GroupBy)
+	if err != nil {
+		return report.Sales{}, err
+	}
+
+	q := salesQuery + `
+		SELECT
+		    ` + g.columns + `,
+		    COALESCE(SUM(s.units), 0), COALESCE(SUM(s.returned_units), 0),
+		    COALESCE(SUM(s.net), 0), COALESCE(SUM(s.tax), 0), COALESCE(SUM(s.gross), 0),
+		    COALESCE(SUM(s.returned), 0),
+		    ` + g.top + `
+		FROM
+		    sales AS s
+		    ` + g.joins + `
+		WHERE ($1::timestamp IS NULL OR s.date >= $1)
+		    AND ($2::timestamp IS NULL OR s.date < $2)
+		GROUP BY ` + g.groupBy + `
+		ORDER BY ` + g.orderBy + `
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q, filter.Period.From, filter.Period.To)
+	if err != nil {
+		return report.Sales{}, r.wrapError(err)
+	}
+	defer rows.Close()
+
+	sales := report.Sales{
+		GroupBy: filter.GroupBy,
+		From:    filter.Period.From,
+		To:      filter.Period.To,
+		Rows:    make([]report.Row, 0),
+	}
+
+	for rows.Next() {
+		var row report.Row
+		var productName, buyerName, categoryName *string
+		var isTop bool
+
+		err = rows.Scan(
+			&row.ProductID, &productName, &row.BuyerID, &buyerName, &row.CategoryID, &categoryName, &row.Period,
+			&row.Units, &row.ReturnedUnits,
+			&row.Net, &row.Tax, &row.Gross, &row.Returned,
+			&isTop,
+		)
+		if err != nil {
+			return report.Sales{}, err
+		}
+		row.ProductName = optional(productName)
+		row.BuyerName = optional(buyerName)
+		row.CategoryName = optional(categoryName)
+
+		sales.Rows = append(sales.Rows, row)
+		if isTop {
+			sales.Total = sales.Total.Add(row.Totals)
+		}
+	}
+
+	return sales, rows.Err()
+}
+
+func optional(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func (r *repository) wrapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+	return err
+}
+
+func NewRepository(client postgresql.Client, logger *logging.Logger) report.Repository {
+	return &repository{
+		client: client,
+		logger: logger,
+	}
+}
@@ -0,0 +1,67 @@
+package report
+
+import (
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/handlers"
+	"restapi-lesson/internal/logging"
+	"restapi-lesson/pkg/daterange"
+)
+
+const (
+	salesReportURL = "/reports/sales"
+)
+
+type handler struct {
+	logger     *logging.Logger
+	repository Repository
+}
+
+func NewHandler(repository Repository, logger *logging.Logger) handlers.Handler {
+	return &handler{
+		repository: repository,
+		logger:     logger,
+	}
+}
+
+func (h *handler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, salesReportURL, apperror.Middleware(h.GetSalesReport))
+}
+
+// GetSalesReport answers e.g. /reports/sales?group_by=product,week&from=2026-01-01&to=2026-03-31.
+// Sales are grouped by product unless group_by says otherwise.
+func (h *handler) GetSalesReport(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("GET SALES REPORT")
+	w.Header().Set("Content-Type", "application/json")
+
+	filter := Filter{GroupBy: []Grouping{GroupByProduct}}
+	if groupBy := r.URL.Query().Get("group_by"); groupBy != "" {
+		var err error
+		if filter.GroupBy, err = ParseGroupBy(groupBy); err != nil {
+			return err
+		}
+	}
+
+	period, err := daterange.FromQuery(r.URL.Query())
+	if err != nil {
+		return apperror.BadRequestError(err.Error())
+	}
+	filter.Period = period
+
+	sales, err := h.repository.Sales(r.Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	salesBytes, err := json.Marshal(sales)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(salesBytes)
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package report
+
+import (
+	"fmt"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/pkg/daterange"
+	"restapi-lesson/pkg/money"
+	"strings"
+	"time"
+)
+
+// Grouping is the dimension sales are added up by.
+type Grouping string
+
+const (
+	GroupByProduct  Grouping = "product"
+	GroupByBuyer    Grouping = "buyer"
+	GroupByCategory Grouping = "category"
+	GroupByDay      Grouping = "day"
+	GroupByWeek     Grouping = "week"
+	GroupByMonth    Grouping = "month"
+)
+
+func (g Grouping) Validate() error {
+	switch g {
+	case GroupByProduct, GroupByBuyer, GroupByCategory, GroupByDay, GroupByWeek, GroupByMonth:
+		return nil
+	}
+	return apperror.BadRequestError(fmt.Sprintf("unknown grouping %q", g))
+}
+
+func (g Grouping) isPeriod() bool {
+	return g == GroupByDay || g == GroupByWeek || g == GroupByMonth
+}
+
+// ParseGroupBy reads a comma separated list of groupings, e.g.
+// "product,week" for the sales of every product in every week. Each
+// dimension may be given once and only one of day, week and month.
+func ParseGroupBy(s string) ([]Grouping, error) {
+	groupBy := make([]Grouping, 0)
+	seen := make(map[Grouping]bool)
+	period := false
+	for _, part := range strings.Split(s, ",") {
+		g := Grouping(strings.TrimSpace(part))
+		if err := g.Validate(); err != nil {
+			return nil, err
+		}
+		if seen[g] {
+			return nil, apperror.BadRequestError(fmt.Sprintf("grouping %q is given twice", g))
+		}
+		if g.isPeriod() {
+			if period {
+				return nil, apperror.BadRequestError("only one of day, week and month can be grouped by")
+			}
+			period = true
+		}
+		seen[g] = true
+		groupBy = append(groupBy, g)
+	}
+	return groupBy, nil
+}
+
+// Filter selects the sales of a report. Sales are dated by their note and
+// returns by their credit note.
+type Filter struct {
+	GroupBy []Grouping
+	Period  daterange.Range
+}
+
+// Sales is a sales report. Only confirmed and paid notes are counted; goods
+// returned with credit notes are taken off with negative quantities and
+// amounts.
+type Sales struct {
+	GroupBy []Grouping `json:"group_by"`
+	From    *time.Time `json:"from"`
+	To      *time.Time `json:"to"`
+	Rows    []Row      `json:"rows"`
+	Total   Totals     `json:"total"`
+}
+
+// Row holds the totals of one combination of product, buyer, category and
+// period. Only the fields the report is grouped by are set. Grouped by
+// category, a category includes its subcategories and the products without a
+// category make up rows with no CategoryID.
+type Row struct {
+	ProductID    *int       `json:"product_id,omitempty"`
+	ProductName  string     `json:"product_name,omitempty"`
+	BuyerID      *int       `json:"buyer_id,omitempty"`
+	BuyerName    string     `json:"buyer_name,omitempty"`
+	CategoryID   *int       `json:"category_id,omitempty"`
+	CategoryName string     `json:"category_name,omitempty"`
+	Period       *time.Time `json:"period,omitempty"`
+	Totals
+}
+
+// Totals are net of returns: Units and the amounts already include the
+// negative ReturnedUnits and Returned.
+type Totals struct {
+	Units         int         `json:"units"`
+	ReturnedUnits int         `json:"returned_units"`
+	Net           money.Money `json:"net"`
+	Tax           money.Money `json:"tax"`
+	Gross         money.Money `json:"gross"`
+	Returned      money.Money `json:"returned"`
+}
+
+func (t Totals) Add(o Totals) Totals {
+	return Totals{
+		Units:         t.Units + o.Units,
+		ReturnedUnits: t.ReturnedUnits + o.ReturnedUnits,
+		Net:           t.Net.Add(o.Net),
+		Tax:           t.Tax.Add(o.Tax),
+		Gross:         t.Gross.Add(o.Gross),
+		Returned:      t.Returned.Add(o.Returned),
+	}
+}
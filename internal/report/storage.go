@@ -0,0 +1,9 @@
+package report
+
+import (
+	"context"
+)
+
+type Repository interface {
+	Sales(ctx context.Context, filter Filter) (Sales, error)
+}
@@ -0,0 +1,88 @@
+package stock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"restapi-lesson/internal/logging"
+	"time"
+)
+
+const callbackTimeout = 5 * time.Second
+
+// LowStock describes a product whose stock fell below its reorder threshold.
+type LowStock struct {
+	ProductID int    `json:"product_id"`
+	Name      string `json:"name"`
+	Amount    int    `json:"amount"`
+	Threshold int    `json:"threshold"`
+}
+
+// Alerter reports products that went low on stock. Every alert is logged and,
+// when a callback URL is configured, posted to it as a JSON array.
+type Alerter struct {
+	logger      *logging.Logger
+	callbackURL string
+	client      *http.Client
+}
+
+func NewAlerter(logger *logging.Logger, callbackURL string) *Alerter {
+	return &Alerter{
+		logger:      logger,
+		callbackURL: callbackURL,
+		client:      &http.Client{Timeout: callbackTimeout},
+	}
+}
+
+// LowStock reports the given products. It is meant to be called after the
+// transaction that changed the stock has been committed. The callback is sent
+// in the background, so a slow receiver never holds up the request.
+func (a *Alerter) LowStock(alerts ...LowStock) {
+	if a == nil || len(alerts) == 0 {
+		return
+	}
+
+	for _, alert := range alerts {
+		a.logger.Info.Printf("LOW STOCK: product %d %q has %d left, reorder threshold is %d",
+			alert.ProductID, alert.Name, alert.Amount, alert.Threshold)
+	}
+
+	if a.callbackURL == "" {
+		return
+	}
+	go func() {
+		if err := a.post(alerts); err != nil {
+			a.logger.Err.Printf("low stock callback failed: %v", err)
+		}
+	}()
+}
+
+func (a *Alerter) post(alerts []LowStock) error {
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), callbackTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%s answered %s", a.callbackURL, resp.Status)
+	}
+
+	return nil
+}
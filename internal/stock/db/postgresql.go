@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/logging"
+	"restapi-lesson/internal/stock"
+	"restapi-lesson/pkg/client/postgresql"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+type repository struct {
+	client  postgresql.Client
+	logger  *logging.Logger
+	alerter *stock.Alerter
+}
+
+func formatQuery(q string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(q, "\t", ""), "\n", " ")
+}
+
+// Create applies a receipt or adjustment posted by hand to the product stock
+// and records it in the journal.
+func (r *repository) Create(ctx context.Context, movement *stock.Movement) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	ref := stock.Ref{Kind: movement.Kind, Reason: movement.Reason, WarehouseID: movement.WarehouseID}
+	m, low, err := stock.Move(ctx, tx, movement.ProductID, movement.Quantity, ref)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+	if low != nil {
+		r.alerter.LowStock(*low)
+	}
+
+	*movement = m
+	return nil
+}
+
+func (r *repository) FindByProduct(ctx context.Context, productID string) (stock.Journal, error) {
+	q := `
+		SELECT
+		    p.id, p.amount, COALESCE(SUM(sm.quantity), 0)
+		FROM
+		    public.product AS p
+		    LEFT JOIN public.stock_movement AS sm ON sm.product_id = p.id
+		WHERE p.id = $1
+		GROUP BY p.id
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+
+	var journal stock.Journal
+	err := r.client.QueryRow(ctx, q, productID).Scan(&journal.ProductID, &journal.Amount, &journal.Total)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return stock.Journal{}, apperror.ErrNotFound
+	}
+	if err != nil {
+		return stock.Journal{}, r.wrapError(err)
+	}
+	journal.Reconciled = journal.Amount == journal.Total
+
+	q = `
+		SELECT
+		    COALESCE(ps.warehouse_id, sm.warehouse_id), COALESCE(ps.amount, 0), COALESCE(sm.total, 0)
+		FROM
+		    (SELECT warehouse_id, amount FROM public.product_stock WHERE product_id = $1) AS ps
+		    FULL JOIN (
+		        SELECT warehouse_id, SUM(quantity) AS total
+		        FROM public.stock_movement
+		        WHERE product_id = $1
+		        GROUP BY warehouse_id
+		    ) AS sm ON sm.warehouse_id = ps.warehouse_id
+		ORDER BY 1
+	`
+
+	rows, err := r.client.Query(ctx, q, journal.ProductID)
+	if err != nil {
+		return stock.Journal{}, r.wrapError(err)
+	}
+	defer rows.Close()
+
+	journal.Warehouses = make([]stock.Balance, 0)
+	for rows.Next() {
+		var b stock.Balance
+		if err = rows.Scan(&b.WarehouseID, &b.Amount, &b.Total); err != nil {
+			return stock.Journal{}, err
+		}
+		if b.Amount != b.Total {
+			journal.Reconciled = false
+		}
+		journal.Warehouses = append(journal.Warehouses, b)
+	}
+	if err = rows.Err(); err != nil {
+		return stock.Journal{}, err
+	}
+	rows.Close()
+
+	q = `
+		SELECT
+		    id, product_id, warehouse_id, kind, quantity, reason, note_id, product_list_id, created_at
+		FROM
+		    public.stock_movement
+		WHERE product_id = $1
+		ORDER BY id
+	`
+
+	rows, err = r.client.Query(ctx, q, journal.ProductID)
+	if err != nil {
+		return stock.Journal{}, r.wrapError(err)
+	}
+	defer rows.Close()
+
+	journal.Movements = make([]stock.Movement, 0)
+	for rows.Next() {
+		var m stock.Movement
+
+		err = rows.Scan(&m.ID, &m.ProductID, &m.WarehouseID, &m.Kind, &m.Quantity, &m.Reason, &m.NoteID, &m.ProductListID, &m.CreatedAt)
+		if err != nil {
+			return stock.Journal{}, err
+		}
+
+		journal.Movements = append(journal.Movements, m)
+	}
+
+	return journal, rows.Err()
+}
+
+func (r *repository) wrapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+	return err
+}
+
+func NewRepository(client postgresql.Client, logger *logging.Logger, alerter *stock.Alerter) stock.Repository {
+	return &repository{
+		client:  client,
+		logger:  logger,
+		alerter: alerter,
+	}
+}
@@ -0,0 +1,99 @@
+package stock
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/handlers"
+	"restapi-lesson/internal/logging"
+	"strconv"
+)
+
+const (
+	productMovementsURL = "/products/:uuid/movements"
+)
+
+type handler struct {
+	logger     *logging.Logger
+	repository Repository
+}
+
+func NewHandler(repository Repository, logger *logging.Logger) handlers.Handler {
+	return &handler{
+		repository: repository,
+		logger:     logger,
+	}
+}
+
+func (h *handler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, productMovementsURL, apperror.Middleware(h.GetMovements))
+	router.HandlerFunc(http.MethodPost, productMovementsURL, apperror.Middleware(h.CreateMovement))
+}
+
+func (h *handler) GetMovements(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("GET STOCK MOVEMENTS")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	productUUID := params.ByName("uuid")
+	if productUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+
+	journal, err := h.repository.FindByProduct(r.Context(), productUUID)
+	if err != nil {
+		return err
+	}
+
+	journalBytes, err := json.Marshal(journal)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(journalBytes)
+
+	return nil
+}
+
+func (h *handler) CreateMovement(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("CREATE STOCK MOVEMENT")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	productUUID := params.ByName("uuid")
+	productID, err := strconv.Atoi(productUUID)
+	if err != nil {
+		return apperror.BadRequestError("uuid query parameter is required and must be an integer")
+	}
+
+	var m Movement
+
+	defer r.Body.Close()
+	if err = json.NewDecoder(r.Body).Decode(&m); err != nil {
+		return apperror.BadRequestError("invalid data")
+	}
+	m.ProductID = productID
+	if err = m.Validate(); err != nil {
+		return err
+	}
+
+	if err = h.repository.Create(r.Context(), &m); err != nil {
+		return err
+	}
+
+	movementBytes, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/products/%d/movements", productID))
+	w.WriteHeader(http.StatusCreated)
+	w.Write(movementBytes)
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package stock
+
+import (
+	"fmt"
+	"restapi-lesson/internal/apperror"
+	"time"
+)
+
+type Kind string
+
+const (
+	KindReceipt    Kind = "receipt"
+	KindSale       Kind = "sale"
+	KindReturn     Kind = "return"
+	KindAdjustment Kind = "adjustment"
+	KindTransfer   Kind = "transfer"
+)
+
+// Movement is an entry of the append-only stock journal. Quantity is
+// positive when goods come into stock and negative when they leave it.
+type Movement struct {
+	ID            int       `json:"id"`
+	ProductID     int       `json:"product_id"`
+	WarehouseID   int       `json:"warehouse_id"`
+	Kind          Kind      `json:"kind"`
+	Quantity      int       `json:"quantity"`
+	Reason        string    `json:"reason"`
+	NoteID        *int      `json:"note_id,omitempty"`
+	ProductListID *int      `json:"product_list_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Validate checks a movement posted by hand. Sales and returns are recorded
+// by notes and credit notes only.
+func (m Movement) Validate() error {
+	switch m.Kind {
+	case KindReceipt:
+		if m.Quantity <= 0 {
+			return apperror.BadRequestError("quantity of a receipt must be positive")
+		}
+	case KindAdjustment:
+		if m.Quantity == 0 {
+			return apperror.BadRequestError("quantity must not be zero")
+		}
+		if m.Reason == "" {
+			return apperror.BadRequestError("reason is required for an adjustment")
+		}
+	case KindSale, KindReturn:
+		return apperror.BadRequestError(fmt.Sprintf("%s movements are recorded by notes and credit notes", m.Kind))
+	case KindTransfer:
+		return apperror.BadRequestError("transfer movements are recorded by warehouse transfers")
+	default:
+		return apperror.BadRequestError(fmt.Sprintf("unknown movement kind %q", m.Kind))
+	}
+
+	return nil
+}
+
+// Journal is the movement history of a product. The stock of a product is
+// reconciled when its amount, in total and in every warehouse, equals the sum
+// of its movements.
+type Journal struct {
+	ProductID  int        `json:"product_id"`
+	Amount     int        `json:"amount"`
+	Total      int        `json:"journal_total"`
+	Reconciled bool       `json:"reconciled"`
+	Warehouses []Balance  `json:"warehouses"`
+	Movements  []Movement `json:"movements"`
+}
+
+// Balance compares the stock of a product in one warehouse with its journal.
+type Balance struct {
+	WarehouseID int `json:"warehouse_id"`
+	Amount      int `json:"amount"`
+	Total       int `json:"journal_total"`
+}
@@ -0,0 +1,230 @@
+package stock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/pkg/client/postgresql"
+	"sort"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Lock takes row locks on the given products in ascending id order, so that
+// transactions touching several products at once can not deadlock each other.
+func Lock(ctx context.Context, client postgresql.Client, productIDs ...int) error {
+	ids := make([]int, len(productIDs))
+	copy(ids, productIDs)
+	sort.Ints(ids)
+
+	q := `
+		SELECT
+		    id
+		FROM
+		    public.product
+		WHERE id = ANY($1)
+		ORDER BY id
+		FOR UPDATE
+	`
+
+	rows, err := client.Query(ctx, q, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+	}
+
+	return rows.Err()
+}
+
+// Ref says why stock moves, in which warehouse and which note and line item,
+// if any, moved it. A zero WarehouseID means the main warehouse, the one with
+// the lowest id. Zero note and line ids are stored as NULL.
+type Ref struct {
+	Kind          Kind
+	Reason        string
+	WarehouseID   int
+	NoteID        int
+	ProductListID int
+}
+
+// Move changes the stock of a product in a warehouse by quantity, which is
+// negative when goods leave stock, and records the change in the stock
+// journal. product.amount holds the total over all warehouses and changes
+// along. Every change is a single statement guarded by the row lock, so
+// concurrent moves of the same product are serialized and can never drive
+// stock negative. When the move takes the product below its reorder
+// threshold the returned LowStock describes it, otherwise it is nil.
+func Move(ctx context.Context, client postgresql.Client, productID, quantity int, ref Ref) (Movement, *LowStock, error) {
+	if quantity == 0 {
+		return Movement{}, nil, apperror.BadRequestError("quantity must not be zero")
+	}
+
+	warehouseID, err := Warehouse(ctx, client, ref.WarehouseID)
+	if err != nil {
+		return Movement{}, nil, err
+	}
+	ref.WarehouseID = warehouseID
+
+	q := `
+		UPDATE 
+    		public.product
+		SET
+			amount = amount + $1
+		WHERE
+		    id = $2 AND amount + $1 >= 0
+		RETURNING name, amount, reorder_threshold
+	`
+
+	low := LowStock{ProductID: productID}
+	err = client.QueryRow(ctx, q, quantity, productID).Scan(&low.Name, &low.Amount, &low.Threshold)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Movement{}, nil, notEnough(ctx, client, productID, warehouseID, -quantity)
+	}
+	if err != nil {
+		return Movement{}, nil, err
+	}
+
+	if quantity > 0 {
+		q = `
+			INSERT INTO public.product_stock 
+			    (product_id, warehouse_id, amount) 
+			VALUES 
+			       ($1, $2, $3) 
+			ON CONFLICT (product_id, warehouse_id) DO UPDATE
+			SET amount = product_stock.amount + EXCLUDED.amount
+		`
+		_, err = client.Exec(ctx, q, productID, warehouseID, quantity)
+		if err != nil {
+			return Movement{}, nil, err
+		}
+	} else {
+		q = `
+			UPDATE 
+			    public.product_stock
+			SET
+			    amount = amount + $1
+			WHERE
+			    product_id = $2 AND warehouse_id = $3 AND amount + $1 >= 0
+		`
+		commandTag, err := client.Exec(ctx, q, quantity, productID, warehouseID)
+		if err != nil {
+			return Movement{}, nil, err
+		}
+		if commandTag.RowsAffected() != 1 {
+			return Movement{}, nil, notEnough(ctx, client, productID, warehouseID, -quantity)
+		}
+	}
+
+	m, err := record(ctx, client, productID, quantity, ref)
+	if err != nil {
+		return Movement{}, nil, err
+	}
+
+	if quantity < 0 && low.Amount < low.Threshold && low.Amount-quantity >= low.Threshold {
+		return m, &low, nil
+	}
+	return m, nil, nil
+}
+
+// Transfer moves quantity units of a product from one warehouse to another.
+// Both sides are booked in the journal; the total stock does not change.
+func Transfer(ctx context.Context, client postgresql.Client, productID, from, to, quantity int, reason string) error {
+	if quantity <= 0 {
+		return apperror.BadRequestError("quantity must be a positive integer")
+	}
+	if from == to {
+		return apperror.BadRequestError("a transfer needs two different warehouses")
+	}
+
+	ref := Ref{Kind: KindTransfer, Reason: reason, WarehouseID: from}
+	if _, _, err := Move(ctx, client, productID, -quantity, ref); err != nil {
+		return err
+	}
+
+	ref.WarehouseID = to
+	_, _, err := Move(ctx, client, productID, quantity, ref)
+	return err
+}
+
+// Warehouse checks that the warehouse exists, or finds the main warehouse
+// when id is zero.
+func Warehouse(ctx context.Context, client postgresql.Client, id int) (int, error) {
+	q := `
+		SELECT
+		    id
+		FROM
+		    public.warehouse
+		WHERE id = COALESCE(NULLIF($1::int, 0), (SELECT MIN(id) FROM public.warehouse))
+	`
+
+	err := client.QueryRow(ctx, q, id).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, apperror.BadRequestError(fmt.Sprintf("warehouse %d does not exist", id))
+	}
+	return id, err
+}
+
+// Reserve takes amount units of the product out of stock, see Move.
+func Reserve(ctx context.Context, client postgresql.Client, productID, amount int, ref Ref) (*LowStock, error) {
+	if amount <= 0 {
+		return nil, apperror.BadRequestError("amount must be a positive integer")
+	}
+
+	_, low, err := Move(ctx, client, productID, -amount, ref)
+	return low, err
+}
+
+// Release puts amount units of the product back into stock, see Move.
+func Release(ctx context.Context, client postgresql.Client, productID, amount int, ref Ref) error {
+	_, _, err := Move(ctx, client, productID, amount, ref)
+	return err
+}
+
+// notEnough explains why a move could not be made: either the product does
+// not exist or the warehouse has less of it than requested.
+func notEnough(ctx context.Context, client postgresql.Client, productID, warehouseID, requested int) error {
+	q := `
+		SELECT
+		    COALESCE((SELECT ps.amount FROM public.product_stock AS ps WHERE ps.product_id = p.id AND ps.warehouse_id = $2), 0)
+		FROM
+		    public.product AS p
+		WHERE p.id = $1
+	`
+
+	var left int
+	err := client.QueryRow(ctx, q, productID, warehouseID).Scan(&left)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return apperror.BadRequestError(fmt.Sprintf("product %d does not exist", productID))
+	}
+	if err != nil {
+		return err
+	}
+
+	return apperror.ConflictError(fmt.Sprintf(
+		"not enough stock for product %d in warehouse %d: %d left, %d requested",
+		productID, warehouseID, left, requested,
+	))
+}
+
+func record(ctx context.Context, client postgresql.Client, productID, quantity int, ref Ref) (Movement, error) {
+	q := `
+		INSERT INTO public.stock_movement 
+		    (product_id, warehouse_id, kind, quantity, reason, note_id, product_list_id) 
+		VALUES 
+		       ($1, $2, $3, $4, $5, NULLIF($6::int, 0), NULLIF($7::int, 0)) 
+		RETURNING id, product_id, warehouse_id, kind, quantity, reason, note_id, product_list_id, created_at
+	`
+
+	var m Movement
+	err := client.QueryRow(ctx, q, productID, ref.WarehouseID, ref.Kind, quantity, ref.Reason, ref.NoteID, ref.ProductListID).
+		Scan(&m.ID, &m.ProductID, &m.WarehouseID, &m.Kind, &m.Quantity, &m.Reason, &m.NoteID, &m.ProductListID, &m.CreatedAt)
+	if err != nil {
+		return Movement{}, err
+	}
+
+	return m, nil
+}
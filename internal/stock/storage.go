@@ -0,0 +1,10 @@
+package stock
+
+import (
+	"context"
+)
+
+type Repository interface {
+	Create(ctx context.Context, movement *Movement) error
+	FindByProduct(ctx context.Context, productID string) (Journal, error)
+}
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/logging"
+	"restapi-lesson/internal/supplier"
+	"restapi-lesson/pkg/client/postgresql"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+type repository struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func formatQuery(q string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(q, "\t", ""), "\n", " ")
+}
+
+func (r *repository) Create(ctx context.Context, supplier *supplier.Supplier) error {
+	q := `
+		INSERT INTO public.supplier 
+		    (name, phone, email) 
+		VALUES 
+		       ($1, $2, $3) 
+		RETURNING id
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+	if err := r.client.QueryRow(ctx, q, supplier.Name, supplier.Phone, supplier.Email).Scan(&supplier.ID); err != nil {
+		return r.wrapError(err)
+	}
+
+	return nil
+}
+
+func (r *repository) FindAll(ctx context.Context) ([]supplier.Supplier, error) {
+	q := `
+		SELECT
+		    id, name, phone, email
+		FROM
+		    public.supplier
+		ORDER BY id
+	`
+
+	rows, err := r.client.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suppliers := make([]supplier.Supplier, 0)
+
+	for rows.Next() {
+		var s supplier.Supplier
+
+		err = rows.Scan(&s.ID, &s.Name, &s.Phone, &s.Email)
+		if err != nil {
+			return nil, err
+		}
+
+		suppliers = append(suppliers, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return suppliers, nil
+}
+
+func (r *repository) FindOne(ctx context.Context, id string) (supplier.Supplier, error) {
+	q := `
+		SELECT
+		    id, name, phone, email
+		FROM
+		    public.supplier
+		WHERE id = $1
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+
+	var s supplier.Supplier
+	err := r.client.QueryRow(ctx, q, id).Scan(&s.ID, &s.Name, &s.Phone, &s.Email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return supplier.Supplier{}, apperror.ErrNotFound
+	}
+	if err != nil {
+		return supplier.Supplier{}, err
+	}
+
+	return s, nil
+}
+
+func (r *repository) Update(ctx context.Context, supplier supplier.Supplier) error {
+	q := `
+		UPDATE 
+    		public.supplier
+		SET
+			name = $1, phone = $2, email = $3
+		WHERE
+		    id = $4
+	`
+
+	commandTag, err := r.client.Exec(ctx, q, supplier.Name, supplier.Phone, supplier.Email, supplier.ID)
+	if err != nil {
+		return r.wrapError(err)
+	}
+	if commandTag.RowsAffected() != 1 {
+		newErr := errors.New("no row found to update")
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+
+	return nil
+}
+
+func (r *repository) Delete(ctx context.Context, id string) error {
+	q := `DELETE FROM public.supplier WHERE id = $1`
+	commandTag, err := r.client.Exec(ctx, q, id)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	if commandTag.RowsAffected() != 1 {
+		newErr := errors.New("no row found to delete")
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+
+	return nil
+}
+
+func (r *repository) wrapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+	return err
+}
+
+func NewRepository(client postgresql.Client, logger *logging.Logger) supplier.Repository {
+	return &repository{
+		client: client,
+		logger: logger,
+	}
+}
@@ -0,0 +1,167 @@
+package supplier
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/handlers"
+	"restapi-lesson/internal/logging"
+	"strconv"
+)
+
+const (
+	suppliersURL = "/suppliers"
+	supplierURL  = "/suppliers/:uuid"
+)
+
+type handler struct {
+	logger     *logging.Logger
+	repository Repository
+}
+
+func NewHandler(repository Repository, logger *logging.Logger) handlers.Handler {
+	return &handler{
+		repository: repository,
+		logger:     logger,
+	}
+}
+
+func (h *handler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, supplierURL, apperror.Middleware(h.GetSupplier))
+	router.HandlerFunc(http.MethodGet, suppliersURL, apperror.Middleware(h.GetAllSuppliers))
+	router.HandlerFunc(http.MethodPost, suppliersURL, apperror.Middleware(h.CreateSupplier))
+	router.HandlerFunc(http.MethodPatch, supplierURL, apperror.Middleware(h.UpdateSupplier))
+	router.HandlerFunc(http.MethodDelete, supplierURL, apperror.Middleware(h.DeleteSupplier))
+}
+
+func (h *handler) GetSupplier(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("GET SUPPLIER")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	supplierUUID := params.ByName("uuid")
+	if supplierUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+	h.logger.Info.Printf("get param: %v", supplierUUID)
+
+	supplier, err := h.repository.FindOne(r.Context(), supplierUUID)
+	if err != nil {
+		return err
+	}
+	supplierBytes, err := json.Marshal(supplier)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(supplierBytes)
+
+	return nil
+}
+
+func (h *handler) GetAllSuppliers(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("GET ALL SUPPLIERS")
+	w.Header().Set("Content-Type", "application/json")
+
+	suppliers, err := h.repository.FindAll(r.Context())
+	if err != nil {
+		return err
+	}
+
+	suppliersBytes, err := json.Marshal(suppliers)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(suppliersBytes)
+
+	return nil
+}
+
+func (h *handler) CreateSupplier(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("CREATE SUPPLIER")
+	w.Header().Set("Content-Type", "application/json")
+
+	var spl Supplier
+
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&spl); err != nil {
+		return apperror.BadRequestError("invalid data")
+	}
+	if err := spl.Validate(); err != nil {
+		return err
+	}
+
+	err := h.repository.Create(r.Context(), &spl)
+	if err != nil {
+		return err
+	}
+
+	supplierUUID := spl.ID
+	w.Header().Set("Location", fmt.Sprintf("%s/%v", suppliersURL, supplierUUID))
+	w.WriteHeader(http.StatusCreated)
+
+	return nil
+}
+
+func (h *handler) UpdateSupplier(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("UPDATE SUPPLIER")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	supplierUUID := params.ByName("uuid")
+	if supplierUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+
+	id, err := strconv.Atoi(supplierUUID)
+	if err != nil {
+		return err
+	}
+
+	var spl Supplier
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&spl); err != nil {
+		return apperror.BadRequestError("invalid data")
+	}
+	spl.ID = id
+	if err = spl.Validate(); err != nil {
+		return err
+	}
+
+	err = h.repository.Update(r.Context(), spl)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *handler) DeleteSupplier(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("DELETE SUPPLIER")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	supplierUUID := params.ByName("uuid")
+	if supplierUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+
+	err := h.repository.Delete(r.Context(), supplierUUID)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
@@ -0,0 +1,17 @@
+package supplier
+
+import "restapi-lesson/internal/apperror"
+
+type Supplier struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Phone string `json:"phone"`
+	Email string `json:"email"`
+}
+
+func (s Supplier) Validate() error {
+	if s.Name == "" {
+		return apperror.BadRequestError("name is required")
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package supplier
+
+import (
+	"context"
+)
+
+type Repository interface {
+	Create(ctx context.Context, supplier *Supplier) error
+	FindAll(ctx context.Context) ([]Supplier, error)
+	FindOne(ctx context.Context, id string) (Supplier, error)
+	Update(ctx context.Context, supplier Supplier) error
+	Delete(ctx context.Context, id string) error
+}
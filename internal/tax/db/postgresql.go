@@ -0,0 +1,153 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/logging"
+	"restapi-lesson/internal/tax"
+	"restapi-lesson/pkg/client/postgresql"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+type repository struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func formatQuery(q string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(q, "\t", ""), "\n", " ")
+}
+
+func (r *repository) Create(ctx context.Context, category *tax.Category) error {
+	q := `
+		INSERT INTO public.tax_category 
+		    (name, rate, inclusive) 
+		VALUES 
+		       ($1, $2, $3) 
+		RETURNING id
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+	if err := r.client.QueryRow(ctx, q, category.Name, category.Rate, category.Inclusive).Scan(&category.ID); err != nil {
+		return r.wrapError(err)
+	}
+
+	return nil
+}
+
+func (r *repository) FindAll(ctx context.Context) ([]tax.Category, error) {
+	q := `
+		SELECT
+		    id, name, rate, inclusive
+		FROM
+		    public.tax_category
+		ORDER BY id
+	`
+
+	rows, err := r.client.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := make([]tax.Category, 0)
+
+	for rows.Next() {
+		var ctg tax.Category
+
+		err = rows.Scan(&ctg.ID, &ctg.Name, &ctg.Rate, &ctg.Inclusive)
+		if err != nil {
+			return nil, err
+		}
+
+		categories = append(categories, ctg)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return categories, nil
+}
+
+func (r *repository) FindOne(ctx context.Context, id string) (tax.Category, error) {
+	q := `
+		SELECT
+		    id, name, rate, inclusive
+		FROM
+		    public.tax_category
+		WHERE id = $1
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+
+	var ctg tax.Category
+	err := r.client.QueryRow(ctx, q, id).Scan(&ctg.ID, &ctg.Name, &ctg.Rate, &ctg.Inclusive)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return tax.Category{}, apperror.ErrNotFound
+	}
+	if err != nil {
+		return tax.Category{}, err
+	}
+
+	return ctg, nil
+}
+
+func (r *repository) Update(ctx context.Context, category tax.Category) error {
+	q := `
+		UPDATE 
+    		public.tax_category
+		SET
+			name = $1, rate = $2, inclusive = $3
+		WHERE
+		    id = $4
+	`
+
+	commandTag, err := r.client.Exec(ctx, q, category.Name, category.Rate, category.Inclusive, category.ID)
+	if err != nil {
+		return r.wrapError(err)
+	}
+	if commandTag.RowsAffected() != 1 {
+		newErr := errors.New("no row found to update")
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+
+	return nil
+}
+
+func (r *repository) Delete(ctx context.Context, id string) error {
+	q := `DELETE FROM public.tax_category WHERE id = $1`
+	commandTag, err := r.client.Exec(ctx, q, id)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	if commandTag.RowsAffected() != 1 {
+		newErr := errors.New("no row found to delete")
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+
+	return nil
+}
+
+func (r *repository) wrapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+	return err
+}
+
+func NewRepository(client postgresql.Client, logger *logging.Logger) tax.Repository {
+	return &repository{
+		client: client,
+		logger: logger,
+	}
+}
@@ -0,0 +1,39 @@
+package tax
+
+import (
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/pkg/money"
+)
+
+// Category is a tax rate products can be assigned to. Inclusive categories
+// have the tax already contained in the product price; for the others it is
+// added on top.
+type Category struct {
+	ID        int         `json:"id"`
+	Name      string      `json:"name"`
+	Rate      money.Money `json:"rate"`
+	Inclusive bool        `json:"inclusive"`
+}
+
+func (c Category) Validate() error {
+	if c.Name == "" {
+		return apperror.BadRequestError("tax category name is required")
+	}
+	if c.Rate.IsNegative() || c.Rate.Cmp(money.FromInt(100)) > 0 {
+		return apperror.BadRequestError("rate must be between 0 and 100")
+	}
+	return nil
+}
+
+// Split works out the net, tax and gross parts of an amount taxed at rate
+// percent. The tax is rounded half away from zero to whole kopecks, the same
+// way the product_list_total view does it.
+func Split(amount, rate money.Money, inclusive bool) (net, tax, gross money.Money) {
+	if inclusive {
+		tax = amount.MulDiv(rate.Cents(), 100*100+rate.Cents())
+		return amount.Sub(tax), tax, amount
+	}
+
+	tax = amount.Percent(rate)
+	return amount, tax, amount.Add(tax)
+}
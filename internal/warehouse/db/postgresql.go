@@ -0,0 +1,210 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/logging"
+	"restapi-lesson/internal/stock"
+	"restapi-lesson/internal/warehouse"
+	"restapi-lesson/pkg/client/postgresql"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+type repository struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func formatQuery(q string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(q, "\t", ""), "\n", " ")
+}
+
+func (r *repository) Create(ctx context.Context, warehouse *warehouse.Warehouse) error {
+	q := `
+		INSERT INTO public.warehouse 
+		    (name, address) 
+		VALUES 
+		       ($1, $2) 
+		RETURNING id
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+	if err := r.client.QueryRow(ctx, q, warehouse.Name, warehouse.Address).Scan(&warehouse.ID); err != nil {
+		return r.wrapError(err)
+	}
+
+	return nil
+}
+
+func (r *repository) FindAll(ctx context.Context) ([]warehouse.Warehouse, error) {
+	q := `
+		SELECT
+		    id, name, address
+		FROM
+		    public.warehouse
+		ORDER BY id
+	`
+
+	rows, err := r.client.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	warehouses := make([]warehouse.Warehouse, 0)
+
+	for rows.Next() {
+		var wh warehouse.Warehouse
+
+		err = rows.Scan(&wh.ID, &wh.Name, &wh.Address)
+		if err != nil {
+			return nil, err
+		}
+
+		warehouses = append(warehouses, wh)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return warehouses, nil
+}
+
+func (r *repository) FindOne(ctx context.Context, id string) (warehouse.Warehouse, error) {
+	q := `
+		SELECT
+		    id, name, address
+		FROM
+		    public.warehouse
+		WHERE id = $1
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+
+	var wh warehouse.Warehouse
+	err := r.client.QueryRow(ctx, q, id).Scan(&wh.ID, &wh.Name, &wh.Address)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return warehouse.Warehouse{}, apperror.ErrNotFound
+	}
+	if err != nil {
+		return warehouse.Warehouse{}, err
+	}
+
+	return wh, nil
+}
+
+func (r *repository) Update(ctx context.Context, warehouse warehouse.Warehouse) error {
+	q := `
+		UPDATE 
+    		public.warehouse
+		SET
+			name = $1, address = $2
+		WHERE
+		    id = $3
+	`
+
+	commandTag, err := r.client.Exec(ctx, q, warehouse.Name, warehouse.Address, warehouse.ID)
+	if err != nil {
+		return r.wrapError(err)
+	}
+	if commandTag.RowsAffected() != 1 {
+		newErr := errors.New("no row found to update")
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+
+	return nil
+}
+
+func (r *repository) Delete(ctx context.Context, id string) error {
+	q := `DELETE FROM public.warehouse WHERE id = $1`
+	commandTag, err := r.client.Exec(ctx, q, id)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	if commandTag.RowsAffected() != 1 {
+		newErr := errors.New("no row found to delete")
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+
+	return nil
+}
+
+// FindStock lists the products held in a warehouse.
+func (r *repository) FindStock(ctx context.Context, id string) ([]warehouse.Stock, error) {
+	if _, err := r.FindOne(ctx, id); err != nil {
+		return nil, err
+	}
+
+	q := `
+		SELECT
+		    p.id, p.name, ps.amount
+		FROM
+		    public.product_stock AS ps
+		    INNER JOIN public.product AS p ON p.id = ps.product_id
+		WHERE ps.warehouse_id = $1 AND ps.amount > 0
+		ORDER BY p.id
+	`
+	r.logger.Info.Println(fmt.Sprintf("SQL Query: %s", formatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q, id)
+	if err != nil {
+		return nil, r.wrapError(err)
+	}
+	defer rows.Close()
+
+	levels := make([]warehouse.Stock, 0)
+	for rows.Next() {
+		var s warehouse.Stock
+		if err = rows.Scan(&s.ProductID, &s.Name, &s.Amount); err != nil {
+			return nil, err
+		}
+		levels = append(levels, s)
+	}
+
+	return levels, rows.Err()
+}
+
+// Transfer moves stock between two warehouses in one transaction, so the
+// goods are never missing from both or present in both.
+func (r *repository) Transfer(ctx context.Context, transfer warehouse.Transfer) error {
+	tx, err := r.client.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err = stock.Lock(ctx, tx, transfer.ProductID); err != nil {
+		return r.wrapError(err)
+	}
+
+	err = stock.Transfer(ctx, tx, transfer.ProductID, transfer.FromWarehouseID, transfer.ToWarehouseID, transfer.Quantity, transfer.Reason)
+	if err != nil {
+		return r.wrapError(err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *repository) wrapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+		r.logger.Err.Println(newErr)
+		return newErr
+	}
+	return err
+}
+
+func NewRepository(client postgresql.Client, logger *logging.Logger) warehouse.Repository {
+	return &repository{
+		client: client,
+		logger: logger,
+	}
+}
@@ -0,0 +1,220 @@
+package warehouse
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"restapi-lesson/internal/apperror"
+	"restapi-lesson/internal/handlers"
+	"restapi-lesson/internal/logging"
+	"strconv"
+)
+
+const (
+	warehousesURL     = "/warehouses"
+	warehouseURL      = "/warehouses/:uuid"
+	warehouseStockURL = "/warehouses/:uuid/stock"
+	transfersURL      = "/warehouses/transfers"
+)
+
+type handler struct {
+	logger     *logging.Logger
+	repository Repository
+}
+
+func NewHandler(repository Repository, logger *logging.Logger) handlers.Handler {
+	return &handler{
+		repository: repository,
+		logger:     logger,
+	}
+}
+
+func (h *handler) Register(router *httprouter.Router) {
+	router.HandlerFunc(http.MethodGet, warehouseURL, apperror.Middleware(h.GetWarehouse))
+	router.HandlerFunc(http.MethodGet, warehousesURL, apperror.Middleware(h.GetAllWarehouses))
+	router.HandlerFunc(http.MethodPost, warehousesURL, apperror.Middleware(h.CreateWarehouse))
+	router.HandlerFunc(http.MethodPatch, warehouseURL, apperror.Middleware(h.UpdateWarehouse))
+	router.HandlerFunc(http.MethodDelete, warehouseURL, apperror.Middleware(h.DeleteWarehouse))
+	router.HandlerFunc(http.MethodGet, warehouseStockURL, apperror.Middleware(h.GetWarehouseStock))
+	router.HandlerFunc(http.MethodPost, transfersURL, apperror.Middleware(h.CreateTransfer))
+}
+
+func (h *handler) GetWarehouse(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("GET WAREHOUSE")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	warehouseUUID := params.ByName("uuid")
+	if warehouseUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+	h.logger.Info.Printf("get param: %v", warehouseUUID)
+
+	warehouse, err := h.repository.FindOne(r.Context(), warehouseUUID)
+	if err != nil {
+		return err
+	}
+	warehouseBytes, err := json.Marshal(warehouse)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(warehouseBytes)
+
+	return nil
+}
+
+func (h *handler) GetAllWarehouses(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("GET ALL WAREHOUSES")
+	w.Header().Set("Content-Type", "application/json")
+
+	warehouses, err := h.repository.FindAll(r.Context())
+	if err != nil {
+		return err
+	}
+
+	warehousesBytes, err := json.Marshal(warehouses)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(warehousesBytes)
+
+	return nil
+}
+
+func (h *handler) CreateWarehouse(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("CREATE WAREHOUSE")
+	w.Header().Set("Content-Type", "application/json")
+
+	var wh Warehouse
+
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&wh); err != nil {
+		return apperror.BadRequestError("invalid data")
+	}
+	if err := wh.Validate(); err != nil {
+		return err
+	}
+
+	err := h.repository.Create(r.Context(), &wh)
+	if err != nil {
+		return err
+	}
+
+	warehouseUUID := wh.ID
+	w.Header().Set("Location", fmt.Sprintf("%s/%v", warehousesURL, warehouseUUID))
+	w.WriteHeader(http.StatusCreated)
+
+	return nil
+}
+
+func (h *handler) UpdateWarehouse(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("UPDATE WAREHOUSE")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	warehouseUUID := params.ByName("uuid")
+	if warehouseUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+
+	id, err := strconv.Atoi(warehouseUUID)
+	if err != nil {
+		return err
+	}
+
+	var wh Warehouse
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&wh); err != nil {
+		return apperror.BadRequestError("invalid data")
+	}
+	wh.ID = id
+	if err = wh.Validate(); err != nil {
+		return err
+	}
+
+	err = h.repository.Update(r.Context(), wh)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *handler) DeleteWarehouse(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("DELETE WAREHOUSE")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	warehouseUUID := params.ByName("uuid")
+	if warehouseUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+
+	err := h.repository.Delete(r.Context(), warehouseUUID)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (h *handler) GetWarehouseStock(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("GET WAREHOUSE STOCK")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.logger.Info.Println("get uuid from context")
+	params := r.Context().Value(httprouter.ParamsKey).(httprouter.Params)
+	warehouseUUID := params.ByName("uuid")
+	if warehouseUUID == "" {
+		return apperror.BadRequestError("uuid query parameter is required and must be a comma separated integers")
+	}
+
+	levels, err := h.repository.FindStock(r.Context(), warehouseUUID)
+	if err != nil {
+		return err
+	}
+
+	levelsBytes, err := json.Marshal(levels)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(levelsBytes)
+
+	return nil
+}
+
+func (h *handler) CreateTransfer(w http.ResponseWriter, r *http.Request) error {
+	h.logger.Info.Println("CREATE WAREHOUSE TRANSFER")
+	w.Header().Set("Content-Type", "application/json")
+
+	var transfer Transfer
+
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&transfer); err != nil {
+		return apperror.BadRequestError("invalid data")
+	}
+	if err := transfer.Validate(); err != nil {
+		return err
+	}
+
+	if err := h.repository.Transfer(r.Context(), transfer); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package warehouse
+
+import "restapi-lesson/internal/apperror"
+
+// Warehouse is a shop or storeroom holding stock. The one with the lowest id
+// is the main warehouse, used whenever a warehouse is not given.
+type Warehouse struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+func (w Warehouse) Validate() error {
+	if w.Name == "" {
+		return apperror.BadRequestError("name is required")
+	}
+	return nil
+}
+
+// Stock is the amount of a product held in a warehouse.
+type Stock struct {
+	ProductID int    `json:"product_id"`
+	Name      string `json:"name"`
+	Amount    int    `json:"amount"`
+}
+
+// Transfer moves Quantity units of a product between two warehouses.
+type Transfer struct {
+	ProductID       int    `json:"product_id"`
+	FromWarehouseID int    `json:"from_warehouse_id"`
+	ToWarehouseID   int    `json:"to_warehouse_id"`
+	Quantity        int    `json:"quantity"`
+	Reason          string `json:"reason"`
+}
+
+func (t Transfer) Validate() error {
+	if t.ProductID == 0 || t.FromWarehouseID == 0 || t.ToWarehouseID == 0 {
+		return apperror.BadRequestError("product_id, from_warehouse_id and to_warehouse_id are required")
+	}
+	if t.FromWarehouseID == t.ToWarehouseID {
+		return apperror.BadRequestError("a transfer needs two different warehouses")
+	}
+	if t.Quantity <= 0 {
+		return apperror.BadRequestError("quantity must be a positive integer")
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package warehouse
+
+import (
+	"context"
+)
+
+type Repository interface {
+	Create(ctx context.Context, warehouse *Warehouse) error
+	FindAll(ctx context.Context) ([]Warehouse, error)
+	FindOne(ctx context.Context, id string) (Warehouse, error)
+	Update(ctx context.Context, warehouse Warehouse) error
+	Delete(ctx context.Context, id string) error
+	FindStock(ctx context.Context, id string) ([]Stock, error)
+	Transfer(ctx context.Context, transfer Transfer) error
+}
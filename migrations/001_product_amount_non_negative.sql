@@ -0,0 +1,8 @@
+-- Stock is now reserved when product list rows are written, so product.amount
+-- must always hold a real, non-negative number.
+UPDATE public.product SET amount = 0 WHERE amount IS NULL OR amount < 0;
+
+ALTER TABLE public.product
+    ALTER COLUMN amount SET DEFAULT 0,
+    ALTER COLUMN amount SET NOT NULL,
+    ADD CONSTRAINT amount_non_negative CHECK (amount >= 0);
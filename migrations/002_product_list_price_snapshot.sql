@@ -0,0 +1,19 @@
+-- Line items keep the product name and price they were sold with.
+ALTER TABLE public.product_list
+    ADD COLUMN name VARCHAR(100),
+    ADD COLUMN price DECIMAL;
+
+-- One-off backfill: existing rows get the current product name and price,
+-- which is the best information we have about past sales.
+UPDATE public.product_list AS pl
+SET
+    name = p.name,
+    price = p.price
+FROM
+    public.product AS p
+WHERE
+    pl.product_id = p.id AND pl.price IS NULL;
+
+ALTER TABLE public.product_list
+    ALTER COLUMN name SET NOT NULL,
+    ALTER COLUMN price SET NOT NULL;
@@ -0,0 +1,9 @@
+-- Prices are exact amounts with two decimal places (kopecks).
+UPDATE public.product SET price = 0.00 WHERE price IS NULL;
+
+ALTER TABLE public.product
+    ALTER COLUMN price TYPE DECIMAL(12, 2) USING ROUND(price, 2),
+    ALTER COLUMN price SET NOT NULL;
+
+ALTER TABLE public.product_list
+    ALTER COLUMN price TYPE DECIMAL(12, 2) USING ROUND(price, 2);
@@ -0,0 +1,8 @@
+-- Notes get a lifecycle status. Notes that already exist are treated as
+-- finalised invoices.
+ALTER TABLE public.note
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'confirmed',
+    ADD CONSTRAINT status_check CHECK (status IN ('draft', 'confirmed', 'paid', 'cancelled'));
+
+ALTER TABLE public.note
+    ALTER COLUMN status SET DEFAULT 'draft';
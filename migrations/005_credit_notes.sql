@@ -0,0 +1,27 @@
+-- Returns are recorded as credit notes referencing the original note and its
+-- product list rows.
+CREATE TABLE public.credit_note
+(
+    id   SERIAL PRIMARY KEY,
+    note_id INT NOT NULL,
+    date TIMESTAMP NOT NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+
+    CONSTRAINT note_id_fk FOREIGN KEY (note_id) REFERENCES public.note (number)
+);
+
+CREATE TABLE public.credit_note_line
+(
+    id   SERIAL PRIMARY KEY,
+    credit_note_id INT NOT NULL,
+    product_list_id INT NOT NULL,
+    product_id INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    price DECIMAL(12, 2) NOT NULL,
+    amount INT NOT NULL,
+
+    CONSTRAINT amount_positive CHECK (amount > 0),
+    CONSTRAINT credit_note_id_fk FOREIGN KEY (credit_note_id) REFERENCES public.credit_note (id),
+    CONSTRAINT product_list_id_fk FOREIGN KEY (product_list_id) REFERENCES public.product_list (id),
+    CONSTRAINT product_id_fk FOREIGN KEY (product_id) REFERENCES public.product (id)
+);
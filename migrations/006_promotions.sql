@@ -0,0 +1,34 @@
+-- Promotions and the discounts they gave on confirmed notes.
+CREATE TABLE public.promotion
+(
+    id   SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    product_id INT,
+    percent DECIMAL(5, 2) NOT NULL DEFAULT 0,
+    amount DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    buy_quantity INT NOT NULL DEFAULT 0,
+    free_quantity INT NOT NULL DEFAULT 0,
+    starts_at TIMESTAMP,
+    ends_at TIMESTAMP,
+
+    CONSTRAINT kind_check CHECK (kind IN ('product_percent', 'note_fixed', 'buy_n_get_m')),
+    CONSTRAINT product_id_fk FOREIGN KEY (product_id) REFERENCES public.product (id)
+);
+
+CREATE TABLE public.product_list_discount
+(
+    id   SERIAL PRIMARY KEY,
+    product_list_id INT NOT NULL,
+    promotion_id INT,
+    name VARCHAR(100) NOT NULL,
+    amount DECIMAL(12, 2) NOT NULL,
+
+    CONSTRAINT product_list_id_fk FOREIGN KEY (product_list_id) REFERENCES public.product_list (id),
+    CONSTRAINT promotion_id_fk FOREIGN KEY (promotion_id) REFERENCES public.promotion (id) ON DELETE SET NULL
+);
+
+-- Credit notes give back the share of the discount that came with the
+-- returned units.
+ALTER TABLE public.credit_note_line
+    ADD COLUMN discount DECIMAL(12, 2) NOT NULL DEFAULT 0;
@@ -0,0 +1,59 @@
+-- Tax categories, tax rate snapshots on line items and the per line totals
+-- view used for note summaries.
+CREATE TABLE public.tax_category
+(
+    id   SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    rate DECIMAL(5, 2) NOT NULL,
+    inclusive BOOLEAN NOT NULL DEFAULT true,
+
+    CONSTRAINT rate_check CHECK (rate >= 0 AND rate <= 100)
+);
+
+ALTER TABLE public.product
+    ADD COLUMN tax_category_id INT,
+    ADD CONSTRAINT tax_category_id_fk FOREIGN KEY (tax_category_id) REFERENCES public.tax_category (id);
+
+-- Existing line items were sold without tax.
+ALTER TABLE public.product_list
+    ADD COLUMN tax_rate DECIMAL(5, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN tax_inclusive BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE public.credit_note_line
+    ADD COLUMN tax DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN gross DECIMAL(12, 2);
+
+UPDATE public.credit_note_line SET gross = price * amount - discount;
+
+ALTER TABLE public.credit_note_line
+    ALTER COLUMN gross SET NOT NULL;
+
+-- product_list_total breaks every line item down into subtotal, discount and
+-- the net, tax and gross parts of what is left. Tax is rounded half away from
+-- zero to kopecks, exactly like tax.Split does in Go.
+CREATE VIEW public.product_list_total AS
+SELECT
+    t.id, t.note_id, t.product_id, t.amount, t.tax_rate,
+    t.subtotal, t.discount,
+    CASE WHEN t.tax_inclusive THEN t.taxable - t.tax ELSE t.taxable END AS net,
+    t.tax,
+    CASE WHEN t.tax_inclusive THEN t.taxable ELSE t.taxable + t.tax END AS gross
+FROM (
+    SELECT
+        pl.id, pl.note_id, pl.product_id, pl.amount, pl.tax_rate, pl.tax_inclusive,
+        pl.price * pl.amount AS subtotal,
+        d.discount,
+        pl.price * pl.amount - d.discount AS taxable,
+        ROUND(
+            (pl.price * pl.amount - d.discount) * pl.tax_rate
+                / CASE WHEN pl.tax_inclusive THEN 100 + pl.tax_rate ELSE 100 END,
+            2
+        ) AS tax
+    FROM
+        public.product_list AS pl
+        LEFT JOIN LATERAL (
+            SELECT COALESCE(SUM(pld.amount), 0) AS discount
+            FROM public.product_list_discount AS pld
+            WHERE pld.product_list_id = pl.id
+        ) AS d ON true
+) AS t;
@@ -0,0 +1,41 @@
+-- Payments ledger and the per note balance view.
+CREATE TABLE public.payment
+(
+    id   SERIAL PRIMARY KEY,
+    note_id INT NOT NULL,
+    amount DECIMAL(12, 2) NOT NULL,
+    method VARCHAR(20) NOT NULL,
+    paid_at TIMESTAMP NOT NULL,
+
+    CONSTRAINT amount_positive CHECK (amount > 0),
+    CONSTRAINT method_check CHECK (method IN ('cash', 'card', 'transfer')),
+    CONSTRAINT note_id_fk FOREIGN KEY (note_id) REFERENCES public.note (number)
+);
+
+-- note_balance shows, for every note, its gross total, the gross total of the
+-- goods returned with credit notes, what was paid and what is still owed.
+CREATE VIEW public.note_balance AS
+SELECT
+    n.number, n.buyer_id, n.status,
+    COALESCE(t.gross, 0) AS gross,
+    COALESCE(c.returned, 0) AS returned,
+    COALESCE(p.paid, 0) AS paid,
+    COALESCE(t.gross, 0) - COALESCE(c.returned, 0) - COALESCE(p.paid, 0) AS outstanding
+FROM
+    public.note AS n
+    LEFT JOIN LATERAL (
+        SELECT SUM(plt.gross) AS gross
+        FROM public.product_list_total AS plt
+        WHERE plt.note_id = n.number
+    ) AS t ON true
+    LEFT JOIN LATERAL (
+        SELECT SUM(cl.gross) AS returned
+        FROM public.credit_note_line AS cl
+            INNER JOIN public.credit_note AS cn ON cn.id = cl.credit_note_id
+        WHERE cn.note_id = n.number
+    ) AS c ON true
+    LEFT JOIN LATERAL (
+        SELECT SUM(pm.amount) AS paid
+        FROM public.payment AS pm
+        WHERE pm.note_id = n.number
+    ) AS p ON true;
@@ -0,0 +1,9 @@
+-- Optional per buyer credit limit and the record of notes that were let
+-- through over it.
+ALTER TABLE public.buyer
+    ADD COLUMN credit_limit DECIMAL(12, 2),
+    ADD CONSTRAINT credit_limit_non_negative CHECK (credit_limit >= 0);
+
+ALTER TABLE public.note
+    ADD COLUMN credit_limit_override BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN credit_limit_override_at TIMESTAMP;
@@ -0,0 +1,4 @@
+-- Per product reorder threshold for low stock alerts.
+ALTER TABLE public.product
+    ADD COLUMN reorder_threshold INT NOT NULL DEFAULT 0,
+    ADD CONSTRAINT reorder_threshold_non_negative CHECK (reorder_threshold >= 0);
@@ -0,0 +1,31 @@
+-- Append-only stock journal. The current stock of every product is booked as
+-- an opening balance, so the journal reconciles with product.amount from the
+-- start.
+CREATE TABLE public.stock_movement
+(
+    id   SERIAL PRIMARY KEY,
+    product_id INT NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    quantity INT NOT NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    note_id INT,
+    product_list_id INT,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+
+    CONSTRAINT kind_check CHECK (kind IN ('receipt', 'sale', 'return', 'adjustment')),
+    CONSTRAINT quantity_non_zero CHECK (quantity <> 0),
+    CONSTRAINT product_id_fk FOREIGN KEY (product_id) REFERENCES public.product (id)
+);
+
+CREATE FUNCTION public.stock_movement_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'stock movements can not be changed or deleted';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER stock_movement_append_only
+    BEFORE UPDATE OR DELETE ON public.stock_movement
+    FOR EACH ROW EXECUTE PROCEDURE public.stock_movement_append_only();
+
+INSERT INTO public.stock_movement (product_id, kind, quantity, reason)
+SELECT id, 'adjustment', amount, 'opening balance' FROM public.product WHERE amount <> 0;
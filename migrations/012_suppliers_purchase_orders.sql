@@ -0,0 +1,34 @@
+-- Suppliers and purchase orders for restocking.
+CREATE TABLE public.supplier
+(
+    id   SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    phone VARCHAR(50) NOT NULL DEFAULT '',
+    email VARCHAR(100) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE public.purchase_order
+(
+    id   SERIAL PRIMARY KEY,
+    supplier_id INT NOT NULL,
+    ordered_at TIMESTAMP NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'ordered',
+
+    CONSTRAINT status_check CHECK (status IN ('ordered', 'partially_received', 'received')),
+    CONSTRAINT supplier_id_fk FOREIGN KEY (supplier_id) REFERENCES public.supplier (id)
+);
+
+CREATE TABLE public.purchase_order_line
+(
+    id   SERIAL PRIMARY KEY,
+    purchase_order_id INT NOT NULL,
+    product_id INT NOT NULL,
+    quantity INT NOT NULL,
+    received INT NOT NULL DEFAULT 0,
+    cost DECIMAL(12, 2) NOT NULL DEFAULT 0,
+
+    CONSTRAINT quantity_positive CHECK (quantity > 0),
+    CONSTRAINT received_range CHECK (received >= 0 AND received <= quantity),
+    CONSTRAINT purchase_order_id_fk FOREIGN KEY (purchase_order_id) REFERENCES public.purchase_order (id),
+    CONSTRAINT product_id_fk FOREIGN KEY (product_id) REFERENCES public.product (id)
+);
@@ -0,0 +1,45 @@
+-- Warehouses with per warehouse stock. Everything held so far goes to the
+-- main warehouse, which also becomes the warehouse of existing notes,
+-- movements and purchase orders.
+CREATE TABLE public.warehouse
+(
+    id   SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    address VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+INSERT INTO public.warehouse (name) VALUES ('Склад');
+
+CREATE TABLE public.product_stock
+(
+    product_id INT NOT NULL,
+    warehouse_id INT NOT NULL,
+    amount INT NOT NULL DEFAULT 0,
+
+    PRIMARY KEY (product_id, warehouse_id),
+    CONSTRAINT amount_non_negative CHECK (amount >= 0),
+    CONSTRAINT product_id_fk FOREIGN KEY (product_id) REFERENCES public.product (id),
+    CONSTRAINT warehouse_id_fk FOREIGN KEY (warehouse_id) REFERENCES public.warehouse (id)
+);
+
+INSERT INTO public.product_stock (product_id, warehouse_id, amount)
+SELECT id, (SELECT MIN(id) FROM public.warehouse), amount FROM public.product;
+
+-- Adding a column with a default does not fire the append-only trigger of
+-- the stock journal.
+ALTER TABLE public.note
+    ADD COLUMN warehouse_id INT NOT NULL DEFAULT 1,
+    ADD CONSTRAINT warehouse_id_fk FOREIGN KEY (warehouse_id) REFERENCES public.warehouse (id);
+ALTER TABLE public.note ALTER COLUMN warehouse_id DROP DEFAULT;
+
+ALTER TABLE public.stock_movement
+    ADD COLUMN warehouse_id INT NOT NULL DEFAULT 1,
+    ADD CONSTRAINT warehouse_id_fk FOREIGN KEY (warehouse_id) REFERENCES public.warehouse (id),
+    DROP CONSTRAINT kind_check,
+    ADD CONSTRAINT kind_check CHECK (kind IN ('receipt', 'sale', 'return', 'adjustment', 'transfer'));
+ALTER TABLE public.stock_movement ALTER COLUMN warehouse_id DROP DEFAULT;
+
+ALTER TABLE public.purchase_order
+    ADD COLUMN warehouse_id INT NOT NULL DEFAULT 1,
+    ADD CONSTRAINT warehouse_id_fk FOREIGN KEY (warehouse_id) REFERENCES public.warehouse (id);
+ALTER TABLE public.purchase_order ALTER COLUMN warehouse_id DROP DEFAULT;
@@ -0,0 +1,13 @@
+-- Product categories form a tree through parent_id.
+CREATE TABLE public.category
+(
+    id   SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    parent_id INT,
+
+    CONSTRAINT parent_id_fk FOREIGN KEY (parent_id) REFERENCES public.category (id)
+);
+
+ALTER TABLE public.product
+    ADD COLUMN category_id INT,
+    ADD CONSTRAINT category_id_fk FOREIGN KEY (category_id) REFERENCES public.category (id);
@@ -0,0 +1,10 @@
+-- Full-text search over product names and descriptions with the russian
+-- configuration, plus pg_trgm for misspelt queries.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE public.product
+    ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('russian', name), 'A') || setweight(to_tsvector('russian', description), 'B')
+    ) STORED;
+
+CREATE INDEX product_search_vector_idx ON public.product USING GIN (search_vector);
@@ -0,0 +1,62 @@
+-- Loyalty points: a ledger per buyer and payments in points.
+CREATE TABLE public.loyalty_point
+(
+    id   SERIAL PRIMARY KEY,
+    buyer_id INT NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    points DECIMAL(12, 2) NOT NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    note_id INT,
+    credit_note_id INT,
+    payment_id INT,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+
+    CONSTRAINT kind_check CHECK (kind IN ('earn', 'redeem', 'reversal')),
+    CONSTRAINT points_non_zero CHECK (points <> 0),
+    CONSTRAINT buyer_id_fk FOREIGN KEY (buyer_id) REFERENCES public.buyer (id)
+);
+
+CREATE INDEX loyalty_point_buyer_id_idx ON public.loyalty_point (buyer_id);
+CREATE INDEX loyalty_point_note_id_idx ON public.loyalty_point (note_id);
+
+CREATE FUNCTION public.loyalty_point_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'loyalty points can not be changed or deleted';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER loyalty_point_append_only
+    BEFORE UPDATE OR DELETE ON public.loyalty_point
+    FOR EACH ROW EXECUTE PROCEDURE public.loyalty_point_append_only();
+
+ALTER TABLE public.payment
+    DROP CONSTRAINT method_check,
+    ADD CONSTRAINT method_check CHECK (method IN ('cash', 'card', 'transfer', 'points'));
+
+-- Points paid on a cancelled note go back to the buyer's points.
+CREATE OR REPLACE VIEW public.note_balance AS
+SELECT
+    n.number, n.buyer_id, n.status,
+    COALESCE(t.gross, 0) AS gross,
+    COALESCE(c.returned, 0) AS returned,
+    COALESCE(p.paid, 0) AS paid,
+    COALESCE(t.gross, 0) - COALESCE(c.returned, 0) - COALESCE(p.paid, 0) AS outstanding
+FROM
+    public.note AS n
+    LEFT JOIN LATERAL (
+        SELECT SUM(plt.gross) AS gross
+        FROM public.product_list_total AS plt
+        WHERE plt.note_id = n.number
+    ) AS t ON true
+    LEFT JOIN LATERAL (
+        SELECT SUM(cl.gross) AS returned
+        FROM public.credit_note_line AS cl
+            INNER JOIN public.credit_note AS cn ON cn.id = cl.credit_note_id
+        WHERE cn.note_id = n.number
+    ) AS c ON true
+    LEFT JOIN LATERAL (
+        SELECT SUM(pm.amount) AS paid
+        FROM public.payment AS pm
+        WHERE pm.note_id = n.number
+            AND (pm.method <> 'points' OR n.status <> 'cancelled')
+    ) AS p ON true;
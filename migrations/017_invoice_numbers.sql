@@ -0,0 +1,34 @@
+-- Confirmed notes get invoice numbers, counted from 1 every year. Notes
+-- confirmed or paid so far are numbered by date with the default prefix;
+-- cancelled notes are left without a number, as it is not known whether they
+-- were confirmed.
+ALTER TABLE public.note
+    ADD COLUMN invoice_number VARCHAR(50) UNIQUE;
+
+CREATE TABLE public.invoice_sequence
+(
+    year INT PRIMARY KEY,
+    last_value INT NOT NULL
+);
+
+WITH numbered AS (
+    SELECT
+        number,
+        date_part('year', COALESCE(date, now()))::int AS year,
+        ROW_NUMBER() OVER (PARTITION BY date_part('year', COALESCE(date, now())) ORDER BY date, number) AS sequence
+    FROM
+        public.note
+    WHERE status IN ('confirmed', 'paid')
+)
+UPDATE public.note AS n
+SET invoice_number = 'INV-' || nb.year || '-' || lpad(nb.sequence::text, 6, '0')
+FROM numbered AS nb
+WHERE nb.number = n.number;
+
+INSERT INTO public.invoice_sequence (year, last_value)
+SELECT
+    split_part(invoice_number, '-', 2)::int, COUNT(*)
+FROM
+    public.note
+WHERE invoice_number IS NOT NULL
+GROUP BY split_part(invoice_number, '-', 2);
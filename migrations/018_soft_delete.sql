@@ -0,0 +1,42 @@
+-- Products, buyers, notes and line items are deleted softly: deleted_at is
+-- set and they can be restored. Deleted line items no longer count towards
+-- their note.
+ALTER TABLE public.product
+    ADD COLUMN deleted_at TIMESTAMP;
+
+ALTER TABLE public.buyer
+    ADD COLUMN deleted_at TIMESTAMP;
+
+ALTER TABLE public.note
+    ADD COLUMN deleted_at TIMESTAMP;
+
+ALTER TABLE public.product_list
+    ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE OR REPLACE VIEW public.product_list_total AS
+SELECT
+    t.id, t.note_id, t.product_id, t.amount, t.tax_rate,
+    t.subtotal, t.discount,
+    CASE WHEN t.tax_inclusive THEN t.taxable - t.tax ELSE t.taxable END AS net,
+    t.tax,
+    CASE WHEN t.tax_inclusive THEN t.taxable ELSE t.taxable + t.tax END AS gross
+FROM (
+    SELECT
+        pl.id, pl.note_id, pl.product_id, pl.amount, pl.tax_rate, pl.tax_inclusive,
+        pl.price * pl.amount AS subtotal,
+        d.discount,
+        pl.price * pl.amount - d.discount AS taxable,
+        ROUND(
+            (pl.price * pl.amount - d.discount) * pl.tax_rate
+                / CASE WHEN pl.tax_inclusive THEN 100 + pl.tax_rate ELSE 100 END,
+            2
+        ) AS tax
+    FROM
+        public.product_list AS pl
+        LEFT JOIN LATERAL (
+            SELECT COALESCE(SUM(pld.amount), 0) AS discount
+            FROM public.product_list_discount AS pld
+            WHERE pld.product_list_id = pl.id
+        ) AS d ON true
+    WHERE pl.deleted_at IS NULL
+) AS t;
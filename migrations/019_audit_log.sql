@@ -0,0 +1,29 @@
+-- audit_log records every change made to products, buyers, notes and line
+-- items: the row as JSON before and after the change and who asked for it.
+CREATE TABLE public.audit_log
+(
+    id   SERIAL PRIMARY KEY,
+    entity VARCHAR(20) NOT NULL,
+    entity_id INT NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    before JSONB,
+    after JSONB,
+    actor VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+
+    CONSTRAINT entity_check CHECK (entity IN ('product', 'buyer', 'note', 'product_list')),
+    CONSTRAINT action_check CHECK (action IN ('create', 'update', 'delete', 'restore'))
+);
+
+CREATE INDEX audit_log_entity_idx ON public.audit_log (entity, entity_id);
+CREATE INDEX audit_log_created_at_idx ON public.audit_log (created_at);
+
+CREATE FUNCTION public.audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit log can not be changed or deleted';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON public.audit_log
+    FOR EACH ROW EXECUTE PROCEDURE public.audit_log_append_only();
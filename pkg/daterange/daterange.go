@@ -0,0 +1,53 @@
+package daterange
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// Range is a half-open interval [From, To). A nil bound leaves that side of
+// the range open.
+type Range struct {
+	From *time.Time
+	To   *time.Time
+}
+
+// FromQuery reads the from and to query parameters. Both accept either a date
+// (2026-01-31) or an RFC 3339 timestamp. A date given as to includes the whole
+// day, so ?from=2026-01-01&to=2026-01-31 covers all of January.
+func FromQuery(values url.Values) (Range, error) {
+	var rng Range
+	var err error
+
+	if rng.From, err = parse(values.Get("from"), false); err != nil {
+		return Range{}, fmt.Errorf("from: %w", err)
+	}
+	if rng.To, err = parse(values.Get("to"), true); err != nil {
+		return Range{}, fmt.Errorf("to: %w", err)
+	}
+	if rng.From != nil && rng.To != nil && !rng.From.Before(*rng.To) {
+		return Range{}, fmt.Errorf("from must be before to")
+	}
+
+	return rng, nil
+}
+
+func parse(value string, end bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(dateLayout, value); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return &t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%q is neither a date nor an RFC 3339 timestamp", value)
+	}
+	return &t, nil
+}
@@ -0,0 +1,273 @@
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// scale is the number of decimal places kept by Money, i.e. kopecks.
+const scale = 2
+
+const unit = 100
+
+var errFormat = errors.New("money: invalid decimal format")
+
+// Money is an exact decimal amount with two decimal places. It is stored as a
+// whole number of hundredths, so addition and multiplication by a quantity
+// never lose precision the way float64 does.
+type Money struct {
+	cents int64
+}
+
+// FromCents returns the amount of the given number of hundredths.
+func FromCents(cents int64) Money {
+	return Money{cents: cents}
+}
+
+// FromInt returns a whole amount without a fractional part.
+func FromInt(n int64) Money {
+	return Money{cents: n * unit}
+}
+
+// Parse reads a decimal string such as "254.90" or "-3". More than two
+// significant decimal places are rejected instead of being silently rounded.
+func Parse(s string) (Money, error) {
+	return parse(s, false)
+}
+
+// MustParse is like Parse but panics on malformed input. It is meant for
+// constants in code.
+func MustParse(s string) Money {
+	m, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func parse(s string, round bool) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Money{}, errFormat
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" {
+		return Money{}, errFormat
+	}
+	if !isDigits(intPart) || !isDigits(fracPart) {
+		return Money{}, errFormat
+	}
+
+	var rest string
+	if len(fracPart) > scale {
+		fracPart, rest = fracPart[:scale], fracPart[scale:]
+	}
+	for len(fracPart) < scale {
+		fracPart += "0"
+	}
+
+	if intPart == "" {
+		intPart = "0"
+	}
+	whole, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("money: %w", err)
+	}
+	frac, _ := strconv.ParseInt(fracPart, 10, 64)
+
+	cents := whole*unit + frac
+	if cents/unit != whole {
+		return Money{}, errors.New("money: value out of range")
+	}
+
+	if strings.Trim(rest, "0") != "" {
+		if !round {
+			return Money{}, fmt.Errorf("money: %q has more than %d decimal places", s, scale)
+		}
+		if rest[0] >= '5' {
+			cents++
+		}
+	}
+
+	if negative {
+		cents = -cents
+	}
+
+	return Money{cents: cents}, nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Cents returns the amount as a whole number of hundredths.
+func (m Money) Cents() int64 {
+	return m.cents
+}
+
+func (m Money) Add(o Money) Money {
+	return Money{cents: m.cents + o.cents}
+}
+
+func (m Money) Sub(o Money) Money {
+	return Money{cents: m.cents - o.cents}
+}
+
+// Mul multiplies the amount by a quantity.
+func (m Money) Mul(quantity int) Money {
+	return Money{cents: m.cents * int64(quantity)}
+}
+
+func (m Money) Neg() Money {
+	return Money{cents: -m.cents}
+}
+
+func (m Money) IsZero() bool {
+	return m.cents == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.cents < 0
+}
+
+// Cmp returns -1, 0 or +1 depending on whether m is less than, equal to or
+// greater than o.
+func (m Money) Cmp(o Money) int {
+	switch {
+	case m.cents < o.cents:
+		return -1
+	case m.cents > o.cents:
+		return 1
+	}
+	return 0
+}
+
+// Percent returns p percent of the amount, rounded half away from zero to
+// whole kopecks. p itself may have two decimal places, e.g. 12.5%.
+func (m Money) Percent(p Money) Money {
+	return Money{cents: divRound(m.cents*p.cents, 100*unit)}
+}
+
+// MulDiv returns the amount multiplied by num/den, rounded half away from
+// zero. It is used to split an amount proportionally.
+func (m Money) MulDiv(num, den int64) Money {
+	return Money{cents: divRound(m.cents*num, den)}
+}
+
+func divRound(a, b int64) int64 {
+	if b < 0 {
+		a, b = -a, -b
+	}
+	q, r := a/b, a%b
+	if r < 0 {
+		r = -r
+	}
+	if 2*r >= b {
+		if a < 0 {
+			q--
+		} else {
+			q++
+		}
+	}
+	return q
+}
+
+// Min returns the smaller of the two amounts.
+func Min(a, b Money) Money {
+	if a.cents < b.cents {
+		return a
+	}
+	return b
+}
+
+// Sum adds up all the amounts.
+func Sum(amounts ...Money) Money {
+	var total Money
+	for _, a := range amounts {
+		total = total.Add(a)
+	}
+	return total
+}
+
+func (m Money) String() string {
+	cents := m.cents
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/unit, cents%unit)
+}
+
+// Scan implements sql.Scanner. The database driver hands DECIMAL values over
+// as their exact text representation; aggregates may carry extra decimal
+// places, which are rounded half away from zero.
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = Money{}
+		return nil
+	case string:
+		parsed, err := parse(v, true)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case []byte:
+		return m.Scan(string(v))
+	case int64:
+		*m = FromInt(v)
+		return nil
+	}
+
+	return fmt.Errorf("money: can not scan %T", src)
+}
+
+// Value implements driver.Valuer, passing the amount as exact text.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// MarshalJSON writes the amount as a JSON number with exactly two decimal
+// places, e.g. 254.90.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts both a JSON number and a quoted decimal string.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+
+	return nil
+}
@@ -0,0 +1,375 @@
+// Package pdf writes simple PDF documents: pages of text and lines. Text is
+// set in embedded TrueType fonts as CID fonts with the Identity-H encoding, so
+// any script the font covers, Cyrillic included, can be used. Fonts are
+// embedded whole and text is not kerned.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// A4 page size in points.
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+)
+
+// em is the glyph space of PDF fonts: widths are given per 1000 units.
+const em = 1000
+
+// Document is a PDF document under construction. Drawing errors are kept and
+// returned by WriteTo, so that layout code does not have to check every call.
+type Document struct {
+	width, height float64
+	fonts         []*Font
+	pages         []*Page
+	err           error
+}
+
+func NewDocument(width, height float64) *Document {
+	return &Document{width: width, height: height}
+}
+
+// Font is a TrueType font embedded in a document.
+type Font struct {
+	ttf    []byte
+	sfnt   *sfnt.Font
+	buf    sfnt.Buffer
+	name   string
+	glyphs map[rune]glyph
+	used   map[sfnt.GlyphIndex]rune
+}
+
+type glyph struct {
+	index sfnt.GlyphIndex
+	width int
+}
+
+// AddFont parses a TrueType font and embeds it in the document.
+func (d *Document) AddFont(ttf []byte) (*Font, error) {
+	f, err := sfnt.Parse(ttf)
+	if err != nil {
+		return nil, err
+	}
+
+	fnt := &Font{
+		ttf:    ttf,
+		sfnt:   f,
+		glyphs: make(map[rune]glyph),
+		used:   make(map[sfnt.GlyphIndex]rune),
+	}
+
+	name, err := f.Name(&fnt.buf, sfnt.NameIDPostScript)
+	if err != nil || name == "" {
+		name = fmt.Sprintf("Font%d", len(d.fonts)+1)
+	}
+	fnt.name = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || strings.ContainsRune("()<>[]{}/%#", r) {
+			return -1
+		}
+		return r
+	}, name)
+
+	d.fonts = append(d.fonts, fnt)
+	return fnt, nil
+}
+
+func (f *Font) glyph(r rune) (glyph, error) {
+	if g, ok := f.glyphs[r]; ok {
+		return g, nil
+	}
+
+	index, err := f.sfnt.GlyphIndex(&f.buf, r)
+	if err != nil {
+		return glyph{}, err
+	}
+	advance, err := f.sfnt.GlyphAdvance(&f.buf, index, fixed.I(em), font.HintingNone)
+	if err != nil {
+		return glyph{}, err
+	}
+
+	g := glyph{index: index, width: advance.Round()}
+	f.glyphs[r] = g
+	return g, nil
+}
+
+// Width returns the width of s set at size points. Characters the font lacks
+// count as zero width.
+func (f *Font) Width(s string, size float64) float64 {
+	var width int
+	for _, r := range s {
+		g, err := f.glyph(r)
+		if err != nil {
+			continue
+		}
+		width += g.width
+	}
+	return float64(width) * size / em
+}
+
+// Page is a page of a document. Coordinates are in points from the bottom
+// left corner of the page.
+type Page struct {
+	doc     *Document
+	content bytes.Buffer
+}
+
+func (d *Document) AddPage() *Page {
+	p := &Page{doc: d}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// PageCount is the number of pages added so far.
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+// Page returns the page with the zero based index i, e.g. to number the pages
+// once all of them are laid out.
+func (d *Document) Page(i int) *Page {
+	return d.pages[i]
+}
+
+// Text draws s with its baseline starting at x, y.
+func (p *Page) Text(f *Font, size, x, y float64, s string) {
+	if s == "" {
+		return
+	}
+
+	var hex strings.Builder
+	for _, r := range s {
+		g, err := f.glyph(r)
+		if err != nil {
+			if p.doc.err == nil {
+				p.doc.err = fmt.Errorf("pdf: glyph for %q: %w", r, err)
+			}
+			return
+		}
+		if _, ok := f.used[g.index]; !ok {
+			f.used[g.index] = r
+		}
+		fmt.Fprintf(&hex, "%04X", uint16(g.index))
+	}
+
+	fmt.Fprintf(&p.content, "BT /%s %s Tf %s %s Td <%s> Tj ET\n",
+		p.doc.fontKey(f), num(size), num(x), num(y), hex.String())
+}
+
+// Line draws a straight line of the given width.
+func (p *Page) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(&p.content, "%s w %s %s m %s %s l S\n",
+		num(width), num(x1), num(y1), num(x2), num(y2))
+}
+
+func (d *Document) fontKey(f *Font) string {
+	for i, fnt := range d.fonts {
+		if fnt == f {
+			return fmt.Sprintf("F%d", i+1)
+		}
+	}
+	return "F0"
+}
+
+func num(v float64) string {
+	s := fmt.Sprintf("%.2f", v)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "" || s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// WriteTo writes the document as PDF.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	out := &writer{w: w}
+	out.printf("%%PDF-1.7\n%%\xe2\xe3\xcf\xd3\n")
+
+	// Object numbers: the catalog, the page tree, five objects per font,
+	// then the page and its content stream for every page.
+	const catalogID, pagesID = 1, 2
+	fontID := func(i int) int { return 3 + 5*i }
+	pageID := func(i int) int { return 3 + 5*len(d.fonts) + 2*i }
+
+	out.object(catalogID, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesID))
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", pageID(i))
+	}
+	out.object(pagesID, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+
+	fonts := make([]string, len(d.fonts))
+	for i, f := range d.fonts {
+		if err := d.writeFont(out, f, fontID(i)); err != nil {
+			return out.n, err
+		}
+		fonts[i] = fmt.Sprintf("/F%d %d 0 R", i+1, fontID(i))
+	}
+
+	for i, p := range d.pages {
+		out.object(pageID(i), fmt.Sprintf(
+			"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+			pagesID, num(d.width), num(d.height), strings.Join(fonts, " "), pageID(i)+1))
+		out.stream(pageID(i)+1, "", p.content.Bytes())
+	}
+
+	out.trailer(catalogID)
+	return out.n, out.err
+}
+
+// writeFont writes the Type0 font, its CID font, font descriptor, font file
+// and ToUnicode map as the five objects starting at id.
+func (d *Document) writeFont(out *writer, f *Font, id int) error {
+	ppem := fixed.I(em)
+	bounds, err := f.sfnt.Bounds(&f.buf, ppem, font.HintingNone)
+	if err != nil {
+		return err
+	}
+	metrics, err := f.sfnt.Metrics(&f.buf, ppem, font.HintingNone)
+	if err != nil {
+		return err
+	}
+
+	indexes := make([]int, 0, len(f.used))
+	for index := range f.used {
+		indexes = append(indexes, int(index))
+	}
+	sort.Ints(indexes)
+
+	widths := make([]string, 0, len(indexes))
+	for _, index := range indexes {
+		g, _ := f.glyph(f.used[sfnt.GlyphIndex(index)])
+		widths = append(widths, fmt.Sprintf("%d [%d]", index, g.width))
+	}
+
+	capHeight := metrics.CapHeight.Round()
+	if capHeight == 0 {
+		capHeight = metrics.Ascent.Round()
+	}
+
+	out.object(id, fmt.Sprintf(
+		"<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		f.name, id+1, id+4))
+	out.object(id+1, fmt.Sprintf(
+		"<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /DW %d /W [%s] /CIDToGIDMap /Identity >>",
+		f.name, id+2, em, strings.Join(widths, " ")))
+	// sfnt measures y downwards, PDF upwards.
+	out.object(id+2, fmt.Sprintf(
+		"<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		f.name, bounds.Min.X.Round(), -bounds.Max.Y.Round(), bounds.Max.X.Round(), -bounds.Min.Y.Round(),
+		metrics.Ascent.Round(), -metrics.Descent.Round(), capHeight, id+3))
+	out.stream(id+3, fmt.Sprintf("/Length1 %d", len(f.ttf)), f.ttf)
+	out.stream(id+4, "", toUnicode(indexes, f.used))
+
+	return nil
+}
+
+// toUnicode maps the glyphs back to text, so that text can be searched and
+// copied from the document.
+func toUnicode(indexes []int, used map[sfnt.GlyphIndex]rune) []byte {
+	var b bytes.Buffer
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	b.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	b.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	b.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+
+	// A bfchar block holds at most 100 entries.
+	for start := 0; start < len(indexes); start += 100 {
+		end := start + 100
+		if end > len(indexes) {
+			end = len(indexes)
+		}
+		fmt.Fprintf(&b, "%d beginbfchar\n", end-start)
+		for _, index := range indexes[start:end] {
+			var dst strings.Builder
+			for _, u := range utf16.Encode([]rune{used[sfnt.GlyphIndex(index)]}) {
+				fmt.Fprintf(&dst, "%04X", u)
+			}
+			fmt.Fprintf(&b, "<%04X> <%s>\n", index, dst.String())
+		}
+		b.WriteString("endbfchar\n")
+	}
+
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return b.Bytes()
+}
+
+// writer writes numbered objects and remembers their offsets for the cross
+// reference table.
+type writer struct {
+	w       io.Writer
+	n       int64
+	err     error
+	offsets map[int]int64
+}
+
+func (o *writer) printf(format string, args ...interface{}) {
+	o.write([]byte(fmt.Sprintf(format, args...)))
+}
+
+func (o *writer) write(p []byte) {
+	if o.err != nil {
+		return
+	}
+	n, err := o.w.Write(p)
+	o.n += int64(n)
+	o.err = err
+}
+
+func (o *writer) object(id int, body string) {
+	if o.offsets == nil {
+		o.offsets = make(map[int]int64)
+	}
+	o.offsets[id] = o.n
+	o.printf("%d 0 obj\n%s\nendobj\n", id, body)
+}
+
+// stream writes data compressed with FlateDecode. extra goes into the stream
+// dictionary.
+func (o *writer) stream(id int, extra string, data []byte) {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(data)
+	zw.Close()
+
+	if o.offsets == nil {
+		o.offsets = make(map[int]int64)
+	}
+	o.offsets[id] = o.n
+	dict := fmt.Sprintf("/Length %d /Filter /FlateDecode", compressed.Len())
+	if extra != "" {
+		dict += " " + extra
+	}
+	o.printf("%d 0 obj\n<< %s >>\nstream\n", id, dict)
+	o.write(compressed.Bytes())
+	o.printf("\nendstream\nendobj\n")
+}
+
+func (o *writer) trailer(rootID int) {
+	size := len(o.offsets) + 1
+	xref := o.n
+
+	o.printf("xref\n0 %d\n0000000000 65535 f \n", size)
+	for id := 1; id < size; id++ {
+		o.printf("%010d 00000 n \n", o.offsets[id])
+	}
+	o.printf("trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", size, rootID, xref)
+}